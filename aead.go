@@ -0,0 +1,52 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/cipher"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// AEADInfo is the HKDF info label used for deriving the key of the
+// AEAD from DoECDHAEAD and NewAEAD.
+const AEADInfo = "tkeyx25519 aead"
+
+// DoECDHAEAD does ECDH with theirPubKey (see DoECDH), and returns a
+// ChaCha20-Poly1305 AEAD keyed from the shared secret, see NewAEAD.
+// The shared secret itself is zeroed, so it can not be misused.
+//
+// The caller is responsible for the nonces: a nonce must never be
+// used twice with the same key, that is with the same domainString,
+// userSecret, requireTouch, and theirPubKey. Since the peer derives
+// the same key, the two sides must also never use the same nonce for
+// their messages, for example by each using its own half of the nonce
+// space, or by deriving separate keys using DirectionalKeys. See also
+// DeriveNonce.
+func (x X25519) DoECDHAEAD(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, theirPubKey [32]byte, opts ...Option) (cipher.AEAD, error) {
+	shared, err := x.DoECDH(domainString, userSecret, requireTouch, theirPubKey, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(shared)
+
+	return NewAEAD(shared)
+}
+
+// NewAEAD returns a ChaCha20-Poly1305 AEAD keyed from shared, as
+// DoECDHAEAD does, for the peer doing ECDH in software. The key is
+// derived using HKDF-SHA256 with shared as input keying material, no
+// salt, and AEADInfo as info.
+func NewAEAD(shared []byte) (cipher.AEAD, error) {
+	key := hkdfSHA256(shared, nil, AEADInfo, chacha20poly1305.KeySize)
+	defer wipe(key)
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("chacha20poly1305.New: %w", err)
+	}
+
+	return aead, nil
+}
@@ -0,0 +1,77 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// ErrAppVersionMismatch matches (using errors.Is) the
+// *AppVersionMismatchError returned when using WithRequiredAppVersion.
+var ErrAppVersionMismatch = errors.New("device app version mismatch")
+
+// AppVersionMismatchError is returned by commands when the device app
+// is not the one required using WithRequiredAppVersion.
+type AppVersionMismatchError struct {
+	RequiredName    string
+	RequiredVersion int
+	ActualName      string
+	ActualVersion   uint32
+}
+
+func (e *AppVersionMismatchError) Error() string {
+	return fmt.Sprintf("device app is %q version %d, required %q version %d",
+		e.ActualName, e.ActualVersion, e.RequiredName, e.RequiredVersion)
+}
+
+func (e *AppVersionMismatchError) Is(target error) bool {
+	return target == ErrAppVersionMismatch
+}
+
+// appName returns the device app name in nameVer, as the
+// concatenation of Name0 and Name1 with trailing spaces removed.
+func appName(nameVer *tkeyclient.NameVersion) string {
+	return strings.TrimRight(nameVer.Name0+nameVer.Name1, " ")
+}
+
+// checkAppVersion checks, the first time it is called, that the
+// device app is the one required using WithRequiredAppVersion. The
+// outcome of a successful check (match or mismatch) is remembered.
+func (x X25519) checkAppVersion() error {
+	if x.opts.requiredApp == nil {
+		return nil
+	}
+
+	x.st.mu.Lock()
+	checkErr, checked := x.st.appVersionErr, x.st.appVersionChecked
+	x.st.mu.Unlock()
+	if checked {
+		return checkErr
+	}
+
+	nameVer, err := x.GetAppNameVersion()
+	if err != nil {
+		return err
+	}
+
+	required := x.opts.requiredApp
+	if appName(nameVer) != required.name || int64(nameVer.Version) != int64(required.version) {
+		checkErr = &AppVersionMismatchError{
+			RequiredName:    required.name,
+			RequiredVersion: required.version,
+			ActualName:      appName(nameVer),
+			ActualVersion:   nameVer.Version,
+		}
+	}
+
+	x.st.mu.Lock()
+	x.st.appVersionErr, x.st.appVersionChecked = checkErr, true
+	x.st.mu.Unlock()
+
+	return checkErr
+}
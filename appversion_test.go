@@ -0,0 +1,47 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRequiredAppVersion(t *testing.T) {
+	var userSecret [UserSecretSize]byte
+
+	// fakeDevice is "tk1 x255" version 1
+	for _, tt := range []struct {
+		name     string
+		app      string
+		version  int
+		mismatch bool
+	}{
+		{"match", "tk1 x255", 1, false},
+		{"other version", "tk1 x255", 2, true},
+		{"other name", "tk1 other", 1, true},
+		{"negative version", "tk1 x255", -1, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			x := newX25519(&fakeDevice{cdi: 1}, []Option{WithRequiredAppVersion(tt.app, tt.version)})
+
+			_, err := x.GetPubKey("test", userSecret, false)
+			if !tt.mismatch {
+				if err != nil {
+					t.Fatalf("GetPubKey: %v", err)
+				}
+				return
+			}
+
+			var mismatch *AppVersionMismatchError
+			if !errors.As(err, &mismatch) || !errors.Is(err, ErrAppVersionMismatch) {
+				t.Fatalf("GetPubKey: got %v, want ErrAppVersionMismatch", err)
+			}
+			if mismatch.RequiredName != tt.app || mismatch.RequiredVersion != tt.version ||
+				mismatch.ActualName != "tk1 x255" || mismatch.ActualVersion != 1 {
+				t.Fatalf("GetPubKey: got %+v", mismatch)
+			}
+		})
+	}
+}
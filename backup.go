@@ -0,0 +1,180 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// An identity backup, as written by ExportIdentities, is a JSON object
+// holding the format name and version, the Argon2id parameters and
+// salt, the nonce, and the ciphertext. The plaintext is the JSON
+// encoded list of identities. It is encrypted with
+// XChaCha20-Poly1305, using a 32 byte key derived from the passphrase
+// with Argon2id, and a random 24 byte nonce. The format and version
+// are authenticated as additional data; the Argon2id parameters and
+// salt are authenticated implicitly, since changing them changes the
+// key.
+const (
+	backupFormat  = "tkeyx25519 identities"
+	backupVersion = 1
+	backupAAD     = "tkeyx25519 identities v1"
+	backupSaltLen = 16
+
+	// Upper bounds on Argon2id parameters accepted when importing,
+	// so a crafted file can not make us use unbounded resources.
+	backupMaxArgon2Time   = 16
+	backupMaxArgon2Memory = 1024 * 1024 // KiB, that is 1 GiB
+)
+
+type backupFile struct {
+	Format        string `json:"format"`
+	Version       int    `json:"version"`
+	Argon2Time    uint32 `json:"argon2_time"`
+	Argon2Memory  uint32 `json:"argon2_memory"`
+	Argon2Threads uint8  `json:"argon2_threads"`
+	Salt          []byte `json:"salt"`
+	Nonce         []byte `json:"nonce"`
+	Ciphertext    []byte `json:"ciphertext"`
+}
+
+// ExportIdentities writes identities to the file at path, encrypted
+// under a key derived from passphrase. Label, Domain, RequireTouch,
+// and PubKey are included. The UserSecret is left out, also if set;
+// without it the identities can not be used, only recognized and
+// recreated given the userSecret. Use ExportIdentitiesWithSecrets to
+// include it.
+//
+// The identities are encrypted with XChaCha20-Poly1305, using a key
+// derived from passphrase with Argon2id, with the same parameters as
+// UserSecretFromPassphrase and a random salt. The file is JSON,
+// holding the parameters, salt, nonce and ciphertext. It is created
+// with mode 0600, and replaced if it exists.
+func ExportIdentities(identities []Identity, passphrase string, path string) error {
+	stripped := make([]Identity, len(identities))
+	for i, id := range identities {
+		id.UserSecret = nil
+		stripped[i] = id
+	}
+
+	return exportIdentities(stripped, passphrase, path)
+}
+
+// ExportIdentitiesWithSecrets is like ExportIdentities, but includes
+// the UserSecret of identities that have it set. Anyone with the file
+// and the passphrase can then use the identities on the TKey, so the
+// passphrase must be strong.
+func ExportIdentitiesWithSecrets(identities []Identity, passphrase string, path string) error {
+	return exportIdentities(identities, passphrase, path)
+}
+
+// ImportIdentities reads identities written by ExportIdentities or
+// ExportIdentitiesWithSecrets from the file at path, decrypting them
+// using passphrase. UserSecret is only set if it was included.
+func ImportIdentities(passphrase string, path string) ([]Identity, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile: %w", err)
+	}
+
+	var f backupFile
+	if err = json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("Unmarshal: %w", err)
+	}
+
+	if f.Format != backupFormat {
+		return nil, fmt.Errorf("not an identity backup (format %q)", f.Format)
+	}
+	if f.Version != backupVersion {
+		return nil, fmt.Errorf("unsupported identity backup version %d", f.Version)
+	}
+	if f.Argon2Time == 0 || f.Argon2Time > backupMaxArgon2Time ||
+		f.Argon2Memory == 0 || f.Argon2Memory > backupMaxArgon2Memory ||
+		f.Argon2Threads == 0 {
+		return nil, errors.New("unreasonable Argon2id parameters")
+	}
+	if len(f.Salt) < backupSaltLen {
+		return nil, fmt.Errorf("salt too short, %d bytes", len(f.Salt))
+	}
+	if len(f.Nonce) != chacha20poly1305.NonceSizeX {
+		return nil, fmt.Errorf("wrong nonce length %d", len(f.Nonce))
+	}
+
+	key := argon2.IDKey([]byte(passphrase), f.Salt, f.Argon2Time, f.Argon2Memory, f.Argon2Threads, chacha20poly1305.KeySize)
+	defer wipe(key)
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("chacha20poly1305.NewX: %w", err)
+	}
+
+	plaintext, err := aead.Open(nil, f.Nonce, f.Ciphertext, []byte(backupAAD))
+	if err != nil {
+		return nil, errors.New("decrypting identities failed, wrong passphrase or corrupted file")
+	}
+	defer wipe(plaintext)
+
+	var identities []Identity
+	if err = json.Unmarshal(plaintext, &identities); err != nil {
+		return nil, fmt.Errorf("Unmarshal: %w", err)
+	}
+
+	return identities, nil
+}
+
+func exportIdentities(identities []Identity, passphrase string, path string) error {
+	if passphrase == "" {
+		return errors.New("empty passphrase")
+	}
+
+	plaintext, err := json.Marshal(identities)
+	if err != nil {
+		return fmt.Errorf("Marshal: %w", err)
+	}
+	defer wipe(plaintext)
+
+	f := backupFile{
+		Format:        backupFormat,
+		Version:       backupVersion,
+		Argon2Time:    PassphraseArgon2Time,
+		Argon2Memory:  PassphraseArgon2Memory,
+		Argon2Threads: PassphraseArgon2Threads,
+		Salt:          make([]byte, backupSaltLen),
+		Nonce:         make([]byte, chacha20poly1305.NonceSizeX),
+	}
+	if _, err = io.ReadFull(rand.Reader, f.Salt); err != nil {
+		return fmt.Errorf("generating salt: %w", err)
+	}
+	if _, err = io.ReadFull(rand.Reader, f.Nonce); err != nil {
+		return fmt.Errorf("generating nonce: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(passphrase), f.Salt, f.Argon2Time, f.Argon2Memory, f.Argon2Threads, chacha20poly1305.KeySize)
+	defer wipe(key)
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return fmt.Errorf("chacha20poly1305.NewX: %w", err)
+	}
+	f.Ciphertext = aead.Seal(nil, f.Nonce, plaintext, []byte(backupAAD))
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Marshal: %w", err)
+	}
+
+	if err = os.WriteFile(path, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("WriteFile: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var backupTestIdentities = []Identity{
+	{
+		Label:      "mail",
+		Domain:     "mail.example.com",
+		PubKey:     []byte{1, 2, 3},
+		UserSecret: []byte{4, 5, 6},
+	},
+	{
+		Label:        "ssh",
+		Domain:       "ssh",
+		RequireTouch: true,
+	},
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	withSecrets := filepath.Join(dir, "with-secrets.json")
+	if err := ExportIdentitiesWithSecrets(backupTestIdentities, "passphrase", withSecrets); err != nil {
+		t.Fatalf("ExportIdentitiesWithSecrets: %v", err)
+	}
+	got, err := ImportIdentities("passphrase", withSecrets)
+	if err != nil {
+		t.Fatalf("ImportIdentities: %v", err)
+	}
+	if !reflect.DeepEqual(got, backupTestIdentities) {
+		t.Fatalf("ImportIdentities: got %+v, want %+v", got, backupTestIdentities)
+	}
+
+	withoutSecrets := filepath.Join(dir, "without-secrets.json")
+	if err = ExportIdentities(backupTestIdentities, "passphrase", withoutSecrets); err != nil {
+		t.Fatalf("ExportIdentities: %v", err)
+	}
+	got, err = ImportIdentities("passphrase", withoutSecrets)
+	if err != nil {
+		t.Fatalf("ImportIdentities: %v", err)
+	}
+	for i, id := range got {
+		if id.UserSecret != nil {
+			t.Fatalf("identity %d: UserSecret exported", i)
+		}
+		if id.Label != backupTestIdentities[i].Label || id.Domain != backupTestIdentities[i].Domain {
+			t.Fatalf("identity %d: got %+v, want %+v", i, id, backupTestIdentities[i])
+		}
+	}
+
+	if _, err = ImportIdentities("wrong passphrase", withSecrets); err == nil {
+		t.Fatalf("ImportIdentities with wrong passphrase succeeded")
+	}
+}
+
+func TestBackupTampered(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "backup.json")
+	if err := ExportIdentities(backupTestIdentities, "passphrase", path); err != nil {
+		t.Fatalf("ExportIdentities: %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var orig backupFile
+	if err = json.Unmarshal(b, &orig); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		tamper  func(f *backupFile)
+		wantErr string
+	}{
+		{"format", func(f *backupFile) { f.Format = "something else" }, "not an identity backup"},
+		{"version", func(f *backupFile) { f.Version = 2 }, "unsupported identity backup version"},
+		{"no time", func(f *backupFile) { f.Argon2Time = 0 }, "unreasonable Argon2id parameters"},
+		{"huge time", func(f *backupFile) { f.Argon2Time = backupMaxArgon2Time + 1 }, "unreasonable Argon2id parameters"},
+		{"huge memory", func(f *backupFile) { f.Argon2Memory = backupMaxArgon2Memory + 1 }, "unreasonable Argon2id parameters"},
+		{"no threads", func(f *backupFile) { f.Argon2Threads = 0 }, "unreasonable Argon2id parameters"},
+		{"no salt", func(f *backupFile) { f.Salt = nil }, "salt too short"},
+		{"short salt", func(f *backupFile) { f.Salt = f.Salt[:backupSaltLen-1] }, "salt too short"},
+		{"short nonce", func(f *backupFile) { f.Nonce = f.Nonce[1:] }, "wrong nonce length"},
+		{"other time", func(f *backupFile) { f.Argon2Time++ }, "decrypting identities failed"},
+		{"other salt", func(f *backupFile) { f.Salt[0] ^= 1 }, "decrypting identities failed"},
+		{"other nonce", func(f *backupFile) { f.Nonce[0] ^= 1 }, "decrypting identities failed"},
+		{"flipped ciphertext", func(f *backupFile) { f.Ciphertext[0] ^= 1 }, "decrypting identities failed"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := orig
+			f.Salt = append([]byte(nil), orig.Salt...)
+			f.Nonce = append([]byte(nil), orig.Nonce...)
+			f.Ciphertext = append([]byte(nil), orig.Ciphertext...)
+			tt.tamper(&f)
+
+			b, err := json.Marshal(f)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			tampered := filepath.Join(dir, "tampered.json")
+			if err = os.WriteFile(tampered, b, 0o600); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+
+			_, err = ImportIdentities("passphrase", tampered)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
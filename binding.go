@@ -0,0 +1,167 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// ErrDeviceChanged is returned when using WithDeviceBinding, if the
+// TKey is no longer the one first talked to.
+var ErrDeviceChanged = errors.New("device changed")
+
+// ErrUnexpectedPubKey is returned when using WithExpectedPubKey, if
+// the TKey's public key is not the expected one.
+var ErrUnexpectedPubKey = errors.New("unexpected public key")
+
+// WithDeviceBinding makes the X25519 remember the device ID (see
+// GetDeviceID) of the TKey the first time it is used, and check it
+// again before every later operation. If it differs, because the TKey
+// was swapped for another one, or the device app was reloaded with
+// another USS, the operation fails with ErrDeviceChanged before doing
+// anything else. This protects long running sessions from silently
+// switching identity. The remembered ID is shared by all copies of the
+// X25519.
+//
+// Each check gets a public key from the TKey, so every operation
+// costs one more command. The default is no binding.
+func WithDeviceBinding() Option {
+	return func(o *options) {
+		o.deviceBinding = true
+	}
+}
+
+// expectedPubKeyLabel is prepended when hashing the key parameters
+// of WithExpectedPubKey, so the userSecret itself is not kept.
+const expectedPubKeyLabel = "tkeyx25519 expected pubkey"
+
+// WithExpectedPubKey makes GetPubKey and DoECDH, when used with
+// domainString, userSecret, and requireTouch, check that the TKey's
+// public key for them is expected, failing with ErrUnexpectedPubKey
+// if not. This catches using the wrong TKey, or a garbled response,
+// in tools with a single identity. The key depends on all three, so
+// other userSecrets or touch settings for the same domain are not
+// checked. GetPubKey checks the key it gets. DoECDH first gets the
+// public key the first time it is used, unless GetPubKey already
+// found it to be the expected one. Once found to be the expected one,
+// this is remembered by all copies of the X25519, and the key is not
+// checked again. The default is no check.
+func WithExpectedPubKey(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, expected []byte) Option {
+	return func(o *options) {
+		o.expectedPubKey = &expectedPubKey{
+			params: expectedPubKeyParams(domainString, userSecret, touchByte(requireTouch)),
+			pub:    append([]byte(nil), expected...),
+		}
+	}
+}
+
+type expectedPubKey struct {
+	params [32]byte // See expectedPubKeyParams
+	pub    []byte
+}
+
+// expectedPubKeyParams hashes the key parameters, to find whether a
+// command is for the key of WithExpectedPubKey.
+func expectedPubKeyParams(domainString string, userSecret [UserSecretSize]byte, touchByte byte) [32]byte {
+	params := keyParameters(domainString, userSecret, touchByte)
+	data := append([]byte(expectedPubKeyLabel), params.Bytes()...)
+	sum := blake2s.Sum256(data)
+	wipe(data)
+	wipe(params.Bytes())
+
+	return sum
+}
+
+// expectedFor returns the expected public key of WithExpectedPubKey,
+// if it is for domainString, userSecret, and touchByte, else nil.
+func (x X25519) expectedFor(domainString string, userSecret [UserSecretSize]byte, touchByte byte) *expectedPubKey {
+	e := x.opts.expectedPubKey
+	if e == nil {
+		return nil
+	}
+
+	params := expectedPubKeyParams(domainString, userSecret, touchByte)
+	if subtle.ConstantTimeCompare(params[:], e.params[:]) != 1 {
+		return nil
+	}
+
+	return e
+}
+
+// verifyPubKey checks pub, the public key for domainString,
+// userSecret, and touchByte, as required by WithExpectedPubKey.
+func (x X25519) verifyPubKey(domainString string, userSecret [UserSecretSize]byte, touchByte byte, pub []byte) error {
+	e := x.expectedFor(domainString, userSecret, touchByte)
+	if e == nil {
+		return nil
+	}
+
+	if subtle.ConstantTimeCompare(pub, e.pub) != 1 {
+		return fmt.Errorf("%w for domain", ErrUnexpectedPubKey)
+	}
+
+	x.st.mu.Lock()
+	defer x.st.mu.Unlock()
+
+	if x.st.pubKeysVerified == nil {
+		x.st.pubKeysVerified = make(map[[32]byte][]byte)
+	}
+	x.st.pubKeysVerified[e.params] = e.pub
+
+	return nil
+}
+
+// checkExpectedPubKey gets and checks the public key for
+// domainString, userSecret, and touchByte, as required by
+// WithExpectedPubKey, unless it has been found to be the expected one
+// already.
+func (x X25519) checkExpectedPubKey(domainString string, userSecret [UserSecretSize]byte, touchByte byte) error {
+	e := x.expectedFor(domainString, userSecret, touchByte)
+	if e == nil {
+		return nil
+	}
+
+	x.st.mu.Lock()
+	verified, ok := x.st.pubKeysVerified[e.params]
+	x.st.mu.Unlock()
+	if ok && subtle.ConstantTimeCompare(verified, e.pub) == 1 {
+		return nil
+	}
+
+	_, _, err := x.getPubKey(domainString, userSecret, touchByte, false, nil)
+
+	return err
+}
+
+// checkDeviceBinding does the check of WithDeviceBinding, using x,
+// which must hold the TKey.
+func (x X25519) checkDeviceBinding() error {
+	if !x.opts.deviceBinding {
+		return nil
+	}
+
+	x.opts.deviceBinding = false
+	id, err := x.GetDeviceID()
+	if err != nil {
+		return err
+	}
+
+	x.st.mu.Lock()
+	defer x.st.mu.Unlock()
+
+	if x.st.boundDeviceID == nil {
+		x.st.boundDeviceID = id
+		return nil
+	}
+
+	if subtle.ConstantTimeCompare(x.st.boundDeviceID, id) != 1 {
+		return ErrDeviceChanged
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpectedPubKeyOtherParams(t *testing.T) {
+	userSecret := [UserSecretSize]byte{1}
+	otherSecret := [UserSecretSize]byte{2}
+
+	pub, err := newX25519(&fakeDevice{cdi: 1}, nil).GetPubKey("test", userSecret, false)
+	if err != nil {
+		t.Fatalf("GetPubKey: %v", err)
+	}
+
+	x := newX25519(&fakeDevice{cdi: 1}, []Option{WithExpectedPubKey("test", userSecret, false, pub)})
+
+	tests := []struct {
+		name         string
+		userSecret   [UserSecretSize]byte
+		requireTouch bool
+	}{
+		{"expected", userSecret, false},
+		{"other userSecret", otherSecret, false},
+		{"other touch", userSecret, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := x.GetPubKey("test", tt.userSecret, tt.requireTouch); err != nil {
+				t.Errorf("GetPubKey: %v", err)
+			}
+			if _, err := x.DoECDH("test", tt.userSecret, tt.requireTouch, [32]byte{9}); err != nil {
+				t.Errorf("DoECDH: %v", err)
+			}
+		})
+	}
+}
+
+func TestExpectedPubKeyMismatch(t *testing.T) {
+	userSecret := [UserSecretSize]byte{1}
+
+	pub, err := newX25519(&fakeDevice{cdi: 1}, nil).GetPubKey("test", userSecret, false)
+	if err != nil {
+		t.Fatalf("GetPubKey: %v", err)
+	}
+
+	// Another TKey
+	x := newX25519(&fakeDevice{cdi: 2}, []Option{WithExpectedPubKey("test", userSecret, false, pub)})
+
+	if _, err = x.GetPubKey("test", userSecret, false); !errors.Is(err, ErrUnexpectedPubKey) {
+		t.Errorf("GetPubKey: got %v, want ErrUnexpectedPubKey", err)
+	}
+	if _, err = x.DoECDH("test", userSecret, false, [32]byte{9}); !errors.Is(err, ErrUnexpectedPubKey) {
+		t.Errorf("DoECDH: got %v, want ErrUnexpectedPubKey", err)
+	}
+}
+
+func TestExpectedPubKeyVerifiedPerParams(t *testing.T) {
+	userSecret := [UserSecretSize]byte{1}
+	otherSecret := [UserSecretSize]byte{2}
+
+	pub, err := newX25519(&fakeDevice{cdi: 1}, nil).GetPubKey("test", userSecret, false)
+	if err != nil {
+		t.Fatalf("GetPubKey: %v", err)
+	}
+
+	x := newX25519(&fakeDevice{cdi: 1}, []Option{WithExpectedPubKey("test", userSecret, false, pub)})
+	if _, err = x.DoECDH("test", userSecret, false, [32]byte{9}); err != nil {
+		t.Fatalf("DoECDH: %v", err)
+	}
+
+	// Having verified one key must not skip the check of another
+	wrong := WithExpectedPubKey("test", otherSecret, false, pub)
+	if _, err = x.DoECDH("test", otherSecret, false, [32]byte{9}, wrong); !errors.Is(err, ErrUnexpectedPubKey) {
+		t.Errorf("DoECDH with other expectation: got %v, want ErrUnexpectedPubKey", err)
+	}
+}
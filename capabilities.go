@@ -0,0 +1,150 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// Capability flags as sent by the device app in the response to
+// cmdGetCapabilities, a little-endian uint32 following the status
+// byte.
+const (
+	capTouchEnforced = 1 << 0
+	capTouchPolicy   = 1 << 1
+	capBatch         = 1 << 2
+	capChunked       = 1 << 3
+	capTypedPubKey   = 1 << 4
+)
+
+// Capabilities are the features advertised by the device app, see
+// GetCapabilities.
+type Capabilities struct {
+	// Known is true if the device app reported its capabilities.
+	// If false, the device app predates capabilities, and all other
+	// fields are false.
+	Known bool
+	// TouchEnforced is true if the device app honours requireTouch,
+	// requiring physical touch for ECDH.
+	TouchEnforced bool
+	// TouchPolicy is true if the device app interprets the touch
+	// byte as a bitmask of touch policies, see
+	// GetPubKeyWithTouchPolicy.
+	TouchPolicy bool
+	// Batch is true if the device app supports batch commands.
+	Batch bool
+	// Chunked is true if the device app supports commands with
+	// payloads spanning multiple frames.
+	Chunked bool
+	// TypedPubKey is true if the device app prefixes the public
+	// key in the response to cmdGetPubKey with its key type and
+	// length, see GetPubKeyTyped.
+	TypedPubKey bool
+	// Flags holds all flags as sent by the device app, including
+	// any not known by this package.
+	Flags uint32
+}
+
+// GetCapabilities asks the device app which features it supports. A
+// device app predating this command responds with a not-OK frame,
+// for which the zero Capabilities (with Known false) is returned,
+// without error. A timeout is used like for GetAppNameVersion, see
+// Timeouts.
+func (x X25519) GetCapabilities(opts ...Option) (Capabilities, error) {
+	x = x.with(opts)
+
+	x, release, err := x.acquire()
+	if err != nil {
+		return Capabilities{}, err
+	}
+	defer release()
+
+	var rx []byte
+	err = x.withReadTimeout(PhaseCommand, x.commandTimeout(true), func() error {
+		var err error
+		rx, err = x.sendCommand(cmdGetCapabilities, bytes.Buffer{}, rspGetCapabilities)
+		return err
+	})
+
+	if errors.Is(err, tkeyclient.ErrResponseStatusNotOK) {
+		return Capabilities{}, nil
+	}
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	flags := binary.LittleEndian.Uint32(rx[:4])
+
+	return Capabilities{
+		Known:         true,
+		TouchEnforced: flags&capTouchEnforced != 0,
+		TouchPolicy:   flags&capTouchPolicy != 0,
+		Batch:         flags&capBatch != 0,
+		Chunked:       flags&capChunked != 0,
+		TypedPubKey:   flags&capTypedPubKey != 0,
+		Flags:         flags,
+	}, nil
+}
+
+// cachedCapabilities returns the device app's capabilities, only
+// asking the TKey the first time.
+func (x X25519) cachedCapabilities() (Capabilities, error) {
+	x.st.mu.Lock()
+	cached := x.st.caps
+	x.st.mu.Unlock()
+	if cached != nil {
+		return *cached, nil
+	}
+
+	caps, err := x.GetCapabilities()
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	x.st.mu.Lock()
+	x.st.caps = &caps
+	x.st.mu.Unlock()
+
+	return caps, nil
+}
+
+// knownCapabilities is like cachedCapabilities, but never asks the
+// device app. If the capabilities have not been cached yet, none are
+// returned.
+func (x X25519) knownCapabilities() Capabilities {
+	x.st.mu.Lock()
+	defer x.st.mu.Unlock()
+
+	if x.st.caps == nil {
+		return Capabilities{}
+	}
+
+	return *x.st.caps
+}
+
+// checkTouchSupported returns ErrTouchUnsupported if the device app
+// reports that it does not enforce touch. A device app that does not
+// report capabilities is assumed to enforce it, which all versions of
+// the X25519 device app predating capabilities do, but a warning is
+// logged.
+func (x X25519) checkTouchSupported() error {
+	caps, err := x.cachedCapabilities()
+	if err != nil {
+		return err
+	}
+
+	if !caps.Known {
+		x.logf("warning: device app does not report capabilities, assuming it enforces touch")
+		return nil
+	}
+	if !caps.TouchEnforced {
+		return ErrTouchUnsupported
+	}
+
+	return nil
+}
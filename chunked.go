@@ -0,0 +1,99 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// ErrChunkedUnsupported is returned by Transact with WithChunked when
+// the device app does not report the Chunked capability.
+var ErrChunkedUnsupported = errors.New("device app does not support chunked commands")
+
+// Chunked commands carry a payload that may be larger than what fits
+// in a single frame. A command opts in to this by being sent using
+// Transact with WithChunked; the device app must implement the same
+// framing for that command. Each frame's data (after the cmd code
+// byte) is then:
+//
+//	byte 0: bit 7 set on the last chunk, bits 6..0 the chunk
+//	        sequence number (starting at 0, wrapping at 128)
+//	byte 1: number of payload bytes n in this chunk
+//	byte 2..2+n: payload
+//
+// A payload that fits in one chunk is sent as a single frame with
+// the last bit set. The device app answers every chunk but the last
+// with a rsp frame carrying only a status byte, and the last chunk
+// with the command's real response.
+const (
+	chunkHdrSize  = 2
+	chunkLastFlag = 0b1000_0000
+	chunkSeqMask  = 0b0111_1111
+)
+
+// WithChunked makes Transact send its data as a chunked command (see
+// above), split across as many frames as needed, so that it can be
+// longer than MaxPayload. The device app must report the Chunked
+// capability (see GetCapabilities), or ErrChunkedUnsupported is
+// returned. Other methods ignore the option.
+func WithChunked() Option {
+	return func(o *options) {
+		o.chunked = true
+	}
+}
+
+// exchangeChunked sends cmd with payload in chunks, and returns the
+// response rsp to the last chunk, like exchange does.
+func (x X25519) exchangeChunked(cmd tkeyclient.Cmd, payload []byte, rsp tkeyclient.Cmd) ([]byte, error) {
+	caps, err := x.cachedCapabilities()
+	if err != nil {
+		return nil, err
+	}
+	if !caps.Chunked {
+		return nil, ErrChunkedUnsupported
+	}
+
+	chunkSize := MaxPayload(cmd) - chunkHdrSize
+	if chunkSize < 1 {
+		return nil, fmt.Errorf("%s too short for chunks", cmd)
+	}
+
+	for seq := 0; ; seq++ {
+		n := len(payload)
+		if n > chunkSize {
+			n = chunkSize
+		}
+		last := n == len(payload)
+
+		flags := byte(seq) & chunkSeqMask
+		if last {
+			flags |= chunkLastFlag
+		}
+
+		data := make([]byte, 0, chunkHdrSize+n)
+		data = append(data, flags, byte(n))
+		data = append(data, payload[:n]...)
+
+		rx, err := x.exchange(cmd, data, rsp)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d: %w", seq, err)
+		}
+
+		if last {
+			return rx, nil
+		}
+
+		if len(rx) < 3 {
+			return nil, fmt.Errorf("chunk %d: no status in %s", seq, rsp)
+		}
+		if rx[2] != StatusOK {
+			return nil, fmt.Errorf("chunk %d: %w", seq, &ResponseStatusNotOKError{code: rx[2]})
+		}
+
+		payload = payload[n:]
+	}
+}
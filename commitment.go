@@ -0,0 +1,43 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// secretCommitmentLabel is prepended to the shared secret in
+// SecretCommitment.
+const secretCommitmentLabel = "tkeyx25519 secret commitment"
+
+// SecretCommitment returns a commitment to shared, for keeping in an
+// audit log instead of the secret itself. It is HMAC-SHA256 with
+// auditKey as key, over secretCommitmentLabel ("tkeyx25519 secret
+// commitment") followed by shared. Given the secret, the holder of
+// auditKey can later show that it is the one committed to, see
+// VerifySecretCommitment. The shared secret itself must never be
+// stored.
+//
+// The commitment shows that a particular secret was in the hands of
+// whoever computed it, not who that was: anyone with auditKey and the
+// secret can compute it, so it is not a signature. Without auditKey
+// it reveals nothing about the secret; with it, it still does not,
+// as long as the secret is a proper ECDH result, but it can be
+// checked against any guessed secret. Keep auditKey as protected as
+// the log is meant to be trustworthy, and use a random one of at least
+// 32 bytes.
+func SecretCommitment(shared []byte, auditKey []byte) []byte {
+	mac := hmac.New(sha256.New, auditKey)
+	mac.Write([]byte(secretCommitmentLabel))
+	mac.Write(shared)
+
+	return mac.Sum(nil)
+}
+
+// VerifySecretCommitment reports, in constant time, whether
+// commitment is the SecretCommitment of shared under auditKey.
+func VerifySecretCommitment(commitment []byte, shared []byte, auditKey []byte) bool {
+	return hmac.Equal(commitment, SecretCommitment(shared, auditKey))
+}
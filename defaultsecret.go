@@ -0,0 +1,57 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+)
+
+// ErrNoDefaultUserSecret is returned by GetPubKeyDefault and
+// DoECDHDefault when no userSecret was set using
+// WithDefaultUserSecret.
+var ErrNoDefaultUserSecret = errors.New("no default userSecret set")
+
+// WithDefaultUserSecret sets the userSecret used by GetPubKeyDefault
+// and DoECDHDefault, for tools that always use the same one. A copy of
+// userSecret is kept, shared by all copies of the X25519, and zeroed
+// by Close; the caller should zero its own. The methods taking an
+// explicit userSecret are not affected.
+func WithDefaultUserSecret(userSecret [UserSecretSize]byte) Option {
+	return func(o *options) {
+		secret := userSecret
+		o.defaultUserSecret = &secret
+	}
+}
+
+// GetPubKeyDefault is GetPubKey, using the userSecret set by
+// WithDefaultUserSecret.
+func (x X25519) GetPubKeyDefault(domainString string, requireTouch bool, opts ...Option) ([]byte, error) {
+	x = x.with(opts)
+
+	if x.opts.defaultUserSecret == nil {
+		return nil, ErrNoDefaultUserSecret
+	}
+
+	return x.GetPubKey(domainString, *x.opts.defaultUserSecret, requireTouch)
+}
+
+// DoECDHDefault is DoECDH, using the userSecret set by
+// WithDefaultUserSecret.
+func (x X25519) DoECDHDefault(domainString string, requireTouch bool, theirPubKey [32]byte, opts ...Option) ([]byte, error) {
+	x = x.with(opts)
+
+	if x.opts.defaultUserSecret == nil {
+		return nil, ErrNoDefaultUserSecret
+	}
+
+	return x.DoECDH(domainString, *x.opts.defaultUserSecret, requireTouch, theirPubKey)
+}
+
+// wipeDefaultUserSecret zeroes the userSecret set by
+// WithDefaultUserSecret, if any.
+func (x X25519) wipeDefaultUserSecret() {
+	if x.opts.defaultUserSecret != nil {
+		wipe(x.opts.defaultUserSecret[:])
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"golang.org/x/crypto/blake2s"
+)
+
+// deviceIDDomain is the domain used for the public key that
+// GetDeviceID derives its ID from. The userSecret used is all zeroes.
+const deviceIDDomain = "tkeyx25519 device id"
+
+// GetDeviceID returns a stable 32-byte identifier for the TKey. The
+// X25519 device app does not expose the TKey's UDI (which can only be
+// read in firmware mode), so the ID is instead a blake2s hash of the
+// public key derived for a fixed domain and an all-zero userSecret,
+// without touch. It thus depends on the same things as every other
+// key: the TKey's UDS, the exact device app binary, and any USS that
+// was used when loading the app. The ID changes if any of those does.
+//
+// The ID is the same for every caller of this package talking to the
+// same TKey with the same app, so it can be used to link uses of the
+// device across contexts. Treat it as you would a hardware serial
+// number, and do not publish it where that would be a privacy
+// concern.
+func (x X25519) GetDeviceID(opts ...Option) ([]byte, error) {
+	var zeroSecret [UserSecretSize]byte
+
+	pubKey, err := x.GetPubKey(deviceIDDomain, zeroSecret, false, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	id := blake2s.Sum256(pubKey)
+
+	return id[:], nil
+}
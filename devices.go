@@ -0,0 +1,135 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// ErrDeviceNotFound is returned by FindDeviceByPubKey when no
+// connected TKey gives the expected public key.
+var ErrDeviceNotFound = errors.New("no TKey with the expected public key found")
+
+// DeviceInfo describes a TKey found by ListDevices.
+type DeviceInfo struct {
+	// Path is the serial port device path, for
+	// tkeyclient.TillitisKey.Connect.
+	Path string
+	// SerialNumber is the USB serial number, if available.
+	SerialNumber string
+}
+
+// ListDevices lists the serial ports of the TKeys plugged in, as
+// identified by their USB VID and PID (see
+// tkeyclient.GetSerialPorts). It does not connect to them, so nothing
+// is known about which app they run. An empty list, not an error, is
+// returned if none is found.
+func ListDevices() ([]DeviceInfo, error) {
+	ports, err := tkeyclient.GetSerialPorts()
+	if err != nil {
+		return nil, fmt.Errorf("GetSerialPorts: %w", err)
+	}
+
+	devices := make([]DeviceInfo, 0, len(ports))
+	for _, p := range ports {
+		devices = append(devices, DeviceInfo{Path: p.DevPath, SerialNumber: p.SerialNumber})
+	}
+
+	return devices, nil
+}
+
+// FindDeviceByPubKey connects to all TKeys plugged in, in parallel,
+// and gets the public key for domainString, userSecret, and
+// requireTouch from each (see GetPubKey). It returns an X25519 for
+// the TKey whose public key equals expectedPub, closing the
+// connections to the others. This requires the X25519 device app to
+// already be running on the TKeys. ErrDeviceNotFound is returned if
+// no TKey matches.
+//
+// Getting the public key uses the command timeout of WithTimeouts,
+// or, if there is none, the short timeout of GetAppNameVersion, so a
+// TKey that does not answer is not waited for. It is taken to not be
+// the TKey looked for.
+func FindDeviceByPubKey(expectedPub []byte, domainString string, userSecret [UserSecretSize]byte, requireTouch bool, opts ...Option) (X25519, error) {
+	ports, err := tkeyclient.GetSerialPorts()
+	if err != nil {
+		return X25519{}, fmt.Errorf("GetSerialPorts: %w", err)
+	}
+
+	matches := make([]bool, len(ports))
+	handles := make([]X25519, len(ports))
+
+	var wg sync.WaitGroup
+	for i := range ports {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			tk := tkeyclient.New()
+			if err := tk.Connect(ports[i].DevPath); err != nil {
+				return
+			}
+			handles[i] = New(tk, opts...)
+			matches[i] = devicePubKeyMatches(handles[i], expectedPub, domainString, userSecret, requireTouch)
+		}(i)
+	}
+	wg.Wait()
+
+	found := -1
+	for i := range ports {
+		if handles[i].tk == nil {
+			continue
+		}
+		if matches[i] && found == -1 {
+			found = i
+			continue
+		}
+		_ = handles[i].Close()
+	}
+
+	if found == -1 {
+		return X25519{}, ErrDeviceNotFound
+	}
+
+	return handles[found], nil
+}
+
+func devicePubKeyMatches(x X25519, expectedPub []byte, domainString string, userSecret [UserSecretSize]byte, requireTouch bool) bool {
+	if x.opts.timeouts.Command == 0 {
+		x.opts.timeouts.Command = nameVersionTimeout * time.Second
+	}
+
+	match, err := x.CouldBeDeviceKey(expectedPub, domainString, userSecret, requireTouch)
+
+	return err == nil && match
+}
+
+// CouldBeDeviceKey tells whether pub is the public key of the TKey for
+// domainString, userSecret, and requireTouch, by getting it (see
+// GetPubKey) and comparing in constant time. This answers whether a
+// stored public key really belongs to this TKey, without needing to
+// know anything about the TKey's CDI. An error means the TKey could
+// not be asked, not that the keys differ.
+func (x X25519) CouldBeDeviceKey(pub []byte, domainString string, userSecret [UserSecretSize]byte, requireTouch bool, opts ...Option) (bool, error) {
+	x = x.with(opts)
+
+	// Getting the name and version has a timeout, so we don't hang
+	// on a TKey running some other app or in firmware mode
+	if _, err := x.GetAppNameVersion(); err != nil {
+		return false, fmt.Errorf("device unreachable: %w", err)
+	}
+
+	devicePub, err := x.GetPubKey(domainString, userSecret, requireTouch)
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare(devicePub, pub) == 1, nil
+}
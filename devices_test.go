@@ -0,0 +1,46 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// silentDevice answers GetNameVersion, but never GetPubKey: reading
+// its response times out if there is a read timeout, and otherwise
+// fails the test instead of hanging.
+type silentDevice struct {
+	fakeDevice
+	t       *testing.T
+	timeout int
+}
+
+func (d *silentDevice) SetReadTimeout(seconds int) error {
+	d.timeout = seconds
+	return nil
+}
+
+func (d *silentDevice) ReadFrame(expectedResp tkeyclient.Cmd, expectedID int) ([]byte, tkeyclient.FramingHdr, error) {
+	if expectedResp.Code() != rspGetPubKey.Code() {
+		return d.fakeDevice.ReadFrame(expectedResp, expectedID)
+	}
+
+	d.pending = false
+	if d.timeout == 0 {
+		d.t.Error("reading GetPubKey response without timeout")
+	}
+	return nil, tkeyclient.FramingHdr{}, fmt.Errorf("Read timeout")
+}
+
+func TestDevicePubKeyMatchesTimeout(t *testing.T) {
+	var userSecret [UserSecretSize]byte
+
+	x := newX25519(&silentDevice{t: t}, nil)
+	if devicePubKeyMatches(x, make([]byte, 32), "test", userSecret, false) {
+		t.Error("silent device matched")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"math/big"
+)
+
+// multicodecX25519Pub is the multicodec code of an X25519 public key,
+// x25519-pub (0xec), as an unsigned varint.
+var multicodecX25519Pub = []byte{0xec, 0x01}
+
+const base58BTCAlphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// DIDKeyPublicKeyMultibase encodes the X25519 public key pub as used
+// in the did:key method and in the publicKeyMultibase of a DID
+// document's verification method (of type X25519KeyAgreementKey2020
+// or Multikey): the multicodec x25519-pub prefix (0xec, as the varint
+// 0xec 0x01) followed by the 32 byte key, base58btc encoded, with the
+// multibase prefix "z". pub is expected to be 32 bytes.
+func DIDKeyPublicKeyMultibase(pub []byte) string {
+	return "z" + base58BTC(append(append([]byte(nil), multicodecX25519Pub...), pub...))
+}
+
+// DIDKey returns the did:key identifier ("did:key:z6LS...") for the
+// X25519 public key pub, see DIDKeyPublicKeyMultibase.
+func DIDKey(pub []byte) string {
+	return "did:key:" + DIDKeyPublicKeyMultibase(pub)
+}
+
+// base58BTC encodes b using the Bitcoin base58 alphabet, with each
+// leading zero byte encoded as "1".
+func base58BTC(b []byte) string {
+	var out []byte
+
+	n := new(big.Int).SetBytes(b)
+	base := big.NewInt(58)
+	mod := new(big.Int)
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		out = append(out, base58BTCAlphabet[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		out = append(out, base58BTCAlphabet[0])
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+
+	return string(out)
+}
@@ -0,0 +1,116 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// domainContextLabel is prepended to the hashed data in
+// DomainWithContext.
+const domainContextLabel = "tkeyx25519 domain context"
+
+// domainEmailLabel is prepended to the hashed data in DomainForEmail.
+const domainEmailLabel = "tkeyx25519 email domain"
+
+// domainRotationLabel is prepended to the hashed data in RotateDomain.
+const domainRotationLabel = "tkeyx25519 domain rotation"
+
+// ErrAmbiguousDomain is returned by ValidateDomain for a domain string
+// that may give the same key as another domain string.
+var ErrAmbiguousDomain = errors.New("ambiguous domain")
+
+// DomainWithContext derives a domain from baseDomain and an
+// application supplied context value, like a session nonce. It is the
+// blake2s-256 hash of domainContextLabel ("tkeyx25519 domain
+// context"), the length of baseDomain as a big-endian uint32,
+// baseDomain, and context. Distinct contexts give distinct domains,
+// and the same baseDomain and context always give the same domain.
+//
+// Pass string(domain[:]) as the domainString of GetPubKey and DoECDH.
+// Since it is exactly 32 bytes it is used as is. This gives a fresh
+// key pair per context, without a new userSecret. As always, the
+// same domain, userSecret and requireTouch must be used for GetPubKey
+// and for DoECDH, so the context must be kept (or recreated) for as
+// long as the key is in use.
+func DomainWithContext(baseDomain string, context []byte) [32]byte {
+	data := append([]byte(domainContextLabel), binary.BigEndian.AppendUint32(nil, uint32(len(baseDomain)))...)
+	data = append(data, baseDomain...)
+	data = append(data, context...)
+
+	return blake2s.Sum256(data)
+}
+
+// RotateDomain derives the domain string for epoch of baseDomain, for
+// rotating keys on a schedule while keeping the same userSecret. It is
+// the blake2s-256 hash of domainRotationLabel ("tkeyx25519 domain
+// rotation"), the length of baseDomain as a big-endian uint32,
+// baseDomain, and epoch as a big-endian uint64, as a 32 byte string,
+// which GetPubKey and DoECDH use as is.
+//
+// Getting the public key for epoch N+1 gives a fresh key, unrelated
+// to that of epoch N. Rotation does not destroy old keys: any epoch
+// can still be derived, given the TKey, userSecret, and requireTouch,
+// so data encrypted to an old epoch's key can still be decrypted. The
+// epoch must therefore be stored along with such data.
+func RotateDomain(baseDomain string, epoch uint64) string {
+	data := append([]byte(domainRotationLabel), binary.BigEndian.AppendUint32(nil, uint32(len(baseDomain)))...)
+	data = append(data, baseDomain...)
+	data = binary.BigEndian.AppendUint64(data, epoch)
+
+	sum := blake2s.Sum256(data)
+
+	return string(sum[:])
+}
+
+// DomainForEmail maps an email address (or username) to a domain, so
+// that the same address always gives the same key, whatever tool is
+// used. The address is normalized by removing leading and trailing
+// white space, and lowercasing each character of it (as
+// strings.ToLower does). Nothing else is done: dots and "+"
+// tags in the local part are kept, and internationalized domain names
+// are not converted to or from punycode, so those forms give
+// different domains. The domain is the blake2s-256 hash of
+// domainEmailLabel ("tkeyx25519 email domain") followed by the
+// normalized address.
+//
+// Pass string(domain[:]) as the domainString of GetPubKey and DoECDH.
+func DomainForEmail(email string) [32]byte {
+	normalized := strings.ToLower(strings.TrimSpace(email))
+
+	return blake2s.Sum256(append([]byte(domainEmailLabel), normalized...))
+}
+
+// ValidateDomain checks that domainString can not give the same keys
+// as another domain string, returning an error wrapping
+// ErrAmbiguousDomain if it can. It is advisory, for checking
+// configuration, and does not change how keys are derived.
+//
+// A domain string of at most 32 bytes is zero padded to 32 bytes (see
+// GetPubKey), so one containing NUL bytes may collide with another:
+// "a\x00" and "a" are padded to the same domain. The empty domain
+// string is padded to all zeroes, which is easily used by mistake.
+// Domain strings without NUL bytes never collide with each other. To
+// use arbitrary bytes, use a domain string longer than 32 bytes, which
+// is hashed.
+func ValidateDomain(domainString string) error {
+	if len(domainString) > 32 {
+		return nil
+	}
+
+	if domainString == "" {
+		return fmt.Errorf("%w: empty domain string", ErrAmbiguousDomain)
+	}
+
+	if i := strings.IndexByte(domainString, 0); i >= 0 {
+		return fmt.Errorf("%w: NUL byte at %d in zero padded domain string; use one longer than 32 bytes, which is hashed", ErrAmbiguousDomain, i)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// ErrDomainLimitExceeded is returned when using WithMaxDomains, if a
+// command would use more distinct domains with a userSecret than
+// allowed.
+var ErrDomainLimitExceeded = errors.New("domain limit exceeded")
+
+// domainLimitLabel is prepended to the userSecret when hashing it to
+// key the domains seen, so the userSecret itself is not kept.
+const domainLimitLabel = "tkeyx25519 domain limit"
+
+// internalDomains are the fixed domains used by this package itself,
+// which WithMaxDomains does not count.
+var internalDomains = map[string]bool{
+	deviceIDDomain: true, // GetDeviceID, also for WithDeviceBinding
+	loopbackDomain: true, // LoopbackTest
+	probeDomain:    true, // ProbeCommands
+}
+
+// WithMaxDomains limits how many distinct domains (as sent to the
+// device app, see GetPubKey) GetPubKey and DoECDH may use with each
+// userSecret, over the lifetime of the X25519 and all its copies. A
+// command using one more domain fails with ErrDomainLimitExceeded,
+// without talking to the TKey. This keeps a compromised or buggy
+// caller from fanning out into many identities. The few fixed domains
+// used internally, by GetDeviceID (and so WithDeviceBinding and
+// Report), LoopbackTest and ProbeCommands, are not counted. The
+// default, 0, means no limit.
+func WithMaxDomains(n int) Option {
+	return func(o *options) {
+		o.maxDomains = n
+	}
+}
+
+// checkDomainLimit records the domain of domainString as used with
+// userSecret, failing if that exceeds the limit of WithMaxDomains.
+func (x X25519) checkDomainLimit(domainString string, userSecret [UserSecretSize]byte) error {
+	if x.opts.maxDomains <= 0 || internalDomains[domainString] {
+		return nil
+	}
+
+	data := append([]byte(domainLimitLabel), userSecret[:]...)
+	secretKey := blake2s.Sum256(data)
+	wipe(data)
+	domain := normalizeDomain(domainString)
+
+	x.st.mu.Lock()
+	defer x.st.mu.Unlock()
+
+	if x.st.domains == nil {
+		x.st.domains = make(map[[32]byte]map[[32]byte]struct{})
+	}
+	seen := x.st.domains[secretKey]
+	if _, ok := seen[domain]; ok {
+		return nil
+	}
+	if len(seen) >= x.opts.maxDomains {
+		return fmt.Errorf("%w: %d domains already used with this userSecret", ErrDomainLimitExceeded, len(seen))
+	}
+
+	if seen == nil {
+		seen = make(map[[32]byte]struct{})
+		x.st.domains[secretKey] = seen
+	}
+	seen[domain] = struct{}{}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"fmt"
+)
+
+// drainTimeout is the read timeout in seconds used when draining, the
+// shortest that tkeyclient supports.
+const drainTimeout = 1
+
+// drainMaxReads bounds the number of reads when draining, in case the
+// TKey keeps sending.
+const drainMaxReads = 1024
+
+// WithFlushBetweenCommands makes X25519 drain any stale input from
+// the connection (see Drain) before sending each command. This works
+// around hosts where a frame, or part of one, is left over after a
+// failed command and then garbles the response to the next one. It
+// adds a second of latency to every command, so only use it on
+// platforms that need it. tkeyclient does not expose the serial
+// port's buffering, so this is the only flush policy available.
+func WithFlushBetweenCommands(flush bool) Option {
+	return func(o *options) {
+		o.flushBetweenCommands = flush
+	}
+}
+
+// Drain reads and throws away whatever the TKey has sent that has not
+// been read, until nothing has arrived for a second. It can be used
+// to get back in sync after a command failed halfway, for example on
+// a read timeout.
+func (x X25519) Drain(opts ...Option) error {
+	x = x.with(opts)
+
+	x, release, err := x.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return x.drain()
+}
+
+func (x X25519) drain() error {
+	discarded := 0
+
+	err := withReadTimeout(x.tk, drainTimeout, func() error {
+		for discarded < drainMaxReads {
+			// Each read consumes at least the header byte, or a
+			// whole frame
+			_, _, err := x.tk.ReadFrame(rspGetNameVersion, 2)
+			if isReadTimeout(err) {
+				return nil
+			}
+			if isIOError(err) {
+				// Not a stale or garbled frame, but failing I/O
+				return fmt.Errorf("ReadFrame: %w", err)
+			}
+			discarded++
+		}
+
+		return fmt.Errorf("still receiving after %d reads", discarded)
+	})
+	if discarded > 0 {
+		x.logf("drained %d stale reads", discarded)
+	}
+
+	return err
+}
@@ -0,0 +1,90 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DoECDHAny does ECDH (see DoECDH) with the first of theirPubKeys that
+// gives a valid shared secret, for when a peer may present any of
+// several keys, such as while migrating to a new key. It returns the
+// index of the key used, and the shared secret.
+//
+// Keys that are points of small order are skipped without talking to
+// the TKey, so no touch is spent on them. Every other key gives a
+// valid shared secret, so normally only one DoECDH is done, and touch
+// is only required once. A failure that is not due to the key, like
+// a touch timeout, is returned right away, with index -1. If all keys
+// are rejected, the error tells why for each of them, and matches
+// (using errors.Is) each of those errors.
+func (x X25519) DoECDHAny(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, theirPubKeys [][32]byte, opts ...Option) (int, []byte, error) {
+	x = x.with(opts)
+
+	if len(theirPubKeys) == 0 {
+		return -1, nil, errors.New("no public keys")
+	}
+
+	x, release, err := x.acquire()
+	if err != nil {
+		return -1, nil, err
+	}
+	defer release()
+
+	var errs multiError
+	for i, pub := range theirPubKeys {
+		if _, err := NormalizePubKey(pub[:]); err != nil {
+			errs = append(errs, fmt.Errorf("key %d: %w", i, err))
+			continue
+		}
+
+		shared, err := x.DoECDH(domainString, userSecret, requireTouch, pub)
+		if errors.Is(err, ErrSmallOrderPoint) {
+			errs = append(errs, fmt.Errorf("key %d: %w", i, err))
+			continue
+		}
+		if err != nil {
+			return -1, nil, err
+		}
+
+		return i, shared, nil
+	}
+
+	return -1, nil, errs
+}
+
+// multiError is several errors, all of which errors.Is and errors.As
+// look at.
+type multiError []error
+
+func (e multiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+func (e multiError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e multiError) As(target any) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"github.com/tillitis/tkeyclient"
+)
+
+// EventType is the type of an Event.
+type EventType int
+
+const (
+	// EventTouchRequired is sent before a command that waits for
+	// the user to touch the TKey.
+	EventTouchRequired EventType = iota
+	// EventCommandSent is sent when a command has been sent.
+	EventCommandSent
+	// EventResponseReceived is sent when a response has been
+	// received and its status is OK.
+	EventResponseReceived
+	// EventError is sent when sending a command or receiving its
+	// response failed, or the response status is not OK.
+	EventError
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventTouchRequired:
+		return "touch required"
+	case EventCommandSent:
+		return "command sent"
+	case EventResponseReceived:
+		return "response received"
+	case EventError:
+		return "error"
+	}
+	return "unknown event"
+}
+
+// Event is something happening while talking to the TKey, see
+// WithEventChannel.
+type Event struct {
+	Type    EventType
+	Command string // Name of the command, like "cmdDoECDH"
+	Err     error  // Set for EventError
+}
+
+// WithEventChannel makes X25519 send an Event on ch for each command
+// it sends, each response it receives, each failure, and before each
+// command requiring touch. This suits event loops like those of
+// Bubble Tea or Fyne, as an alternative to WithLogger.
+//
+// Events are sent without blocking: if ch is full, the event is
+// dropped, so a slow reader never stalls talking to the TKey. Use a
+// buffered channel, and do not rely on seeing every event. ch is
+// never closed by X25519.
+func WithEventChannel(ch chan<- Event) Option {
+	return func(o *options) {
+		o.events = ch
+	}
+}
+
+func (x X25519) emit(t EventType, cmd tkeyclient.Cmd, err error) {
+	if x.opts.events == nil {
+		return
+	}
+
+	select {
+	case x.opts.events <- Event{Type: t, Command: cmd.String(), Err: err}:
+	default:
+	}
+}
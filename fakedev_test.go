@@ -0,0 +1,107 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"fmt"
+
+	"github.com/tillitis/tkeyclient"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/curve25519"
+)
+
+// fakeDevice is a Transport emulating the X25519 device app, for
+// tests. The private key is derived from cdi and the key parameters,
+// not the same way as the real device app, but deterministically.
+// Reading without having written a command times out, like reading
+// from a TKey that has nothing more to send.
+type fakeDevice struct {
+	cdi     byte
+	last    []byte
+	pending bool
+	// stale is the number of responses to send with the wrong frame
+	// id, as if left over from an earlier command.
+	stale int
+	// touchTimeout makes ECDH requiring touch fail as if the user
+	// did not touch the TKey in time.
+	touchTimeout bool
+}
+
+func (f *fakeDevice) Write(d []byte) error {
+	f.last = append([]byte(nil), d...)
+	f.pending = true
+
+	return nil
+}
+
+func (f *fakeDevice) SetReadTimeout(int) error {
+	return nil
+}
+
+func (f *fakeDevice) Close() error {
+	return nil
+}
+
+func (f *fakeDevice) privKey(params []byte) []byte {
+	priv := blake2s.Sum256(append([]byte{f.cdi}, params...))
+
+	return priv[:]
+}
+
+func (f *fakeDevice) ReadFrame(expectedResp tkeyclient.Cmd, expectedID int) ([]byte, tkeyclient.FramingHdr, error) {
+	if !f.pending {
+		return nil, tkeyclient.FramingHdr{}, fmt.Errorf("Read timeout")
+	}
+	f.pending = false
+
+	if f.stale > 0 {
+		f.stale--
+		hdr := tkeyclient.FramingHdr{
+			ID:       byte(expectedID%3 + 1),
+			Endpoint: expectedResp.Endpoint(),
+			CmdLen:   expectedResp.CmdLen(),
+		}
+		return nil, hdr, fmt.Errorf("Expected ID %d, got %d", expectedID, hdr.ID)
+	}
+
+	hdr := tkeyclient.FramingHdr{
+		ID:       byte(expectedID),
+		Endpoint: expectedResp.Endpoint(),
+		CmdLen:   expectedResp.CmdLen(),
+	}
+	rx := make([]byte, 1+expectedResp.CmdLen().Bytelen())
+	rx[0] = formatFramingHdr(hdr)
+	rx[1] = expectedResp.Code()
+
+	// Key parameters are domain (32), userSecret (32), touch (1)
+	data := f.last[2:]
+	switch f.last[1] {
+	case cmdGetNameVersion.Code():
+		copy(rx[2:], "tk1 x255")
+		rx[10] = 1
+	case cmdGetPubKey.Code():
+		pub, err := curve25519.X25519(f.privKey(data[:65]), curve25519.Basepoint)
+		if err != nil {
+			return nil, hdr, err
+		}
+		copy(rx[3:], pub)
+	case cmdDoECDH.Code():
+		if f.touchTimeout && data[64] != 0 {
+			rx[2] = StatusTouchTimeout
+			break
+		}
+		shared, err := curve25519.X25519(f.privKey(data[:65]), data[65:97])
+		if err != nil {
+			return nil, hdr, err
+		}
+		copy(rx[3:], shared)
+	default:
+		hdr.ResponseNotOK = true
+		return nil, hdr, tkeyclient.ErrResponseStatusNotOK
+	}
+
+	return rx, hdr, nil
+}
+
+var _ Transport = (*fakeDevice)(nil)
@@ -0,0 +1,122 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// avatarSeedLabel is prepended to the public key when hashing it in
+// PubKeyAvatarSeed.
+const avatarSeedLabel = "tkeyx25519 avatar seed"
+
+// sessionFingerprintLabel is prepended to the shared secret when
+// hashing it in SecretFingerprint.
+const sessionFingerprintLabel = "tkeyx25519 session fingerprint"
+
+// confirmationCodeLabel is the message authenticated with the shared
+// secret in ConfirmationCode.
+const confirmationCodeLabel = "tkeyx25519 confirmation code"
+
+// sessionFingerprintSize is the number of hash bytes in a fingerprint
+// from SecretFingerprint.
+const sessionFingerprintSize = 10
+
+// PubKeyAvatarSeed maps a public key to a seed for generating an
+// identicon or other avatar, so that the same public key is shown
+// the same way everywhere. The seed is the first 8 bytes, read as a
+// little-endian uint64, of the blake2s-256 hash of avatarSeedLabel
+// ("tkeyx25519 avatar seed") followed by the public key.
+//
+// The seed is a visual aid only. 64 bits are not enough for
+// verifying a public key; compare the whole key for that.
+func PubKeyAvatarSeed(pub []byte) uint64 {
+	sum := blake2s.Sum256(append([]byte(avatarSeedLabel), pub...))
+
+	return binary.LittleEndian.Uint64(sum[:8])
+}
+
+// SecretFingerprint returns a short fingerprint of a shared secret,
+// for people to compare. It is the first 10 bytes of the blake2s-256
+// hash of sessionFingerprintLabel ("tkeyx25519 session fingerprint")
+// followed by the shared secret, as 5 space separated groups of 4 hex
+// digits. The shared secret can not be recovered from it.
+func SecretFingerprint(shared []byte) string {
+	sum := blake2s.Sum256(append([]byte(sessionFingerprintLabel), shared...))
+	digits := hex.EncodeToString(sum[:sessionFingerprintSize])
+
+	groups := make([]string, 0, len(digits)/4)
+	for i := 0; i < len(digits); i += 4 {
+		groups = append(groups, digits[i:i+4])
+	}
+
+	return strings.Join(groups, " ")
+}
+
+// SessionFingerprint does DoECDH with theirPubKey, and returns the
+// SecretFingerprint of the shared secret. The shared secret itself is
+// wiped.
+//
+// ECDH alone does not authenticate the peer: someone in the middle
+// can do ECDH with each party. If both parties compare their session
+// fingerprints over another channel, like reading them out loud on a
+// call, and they are the same, they share the same secret and no one
+// is in the middle.
+func (x X25519) SessionFingerprint(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, theirPubKey [32]byte, opts ...Option) (string, error) {
+	shared, err := x.DoECDH(domainString, userSecret, requireTouch, theirPubKey, opts...)
+	if err != nil {
+		return "", err
+	}
+	defer wipe(shared)
+
+	return SecretFingerprint(shared), nil
+}
+
+// ConfirmSharedSecret tells whether peerFingerprint, the
+// SecretFingerprint the peer got for its shared secret, is that of
+// shared, our shared secret, comparing in constant time. Both sides
+// getting true means they derived the same shared secret.
+func ConfirmSharedSecret(shared []byte, peerFingerprint string) bool {
+	return subtle.ConstantTimeCompare([]byte(SecretFingerprint(shared)), []byte(peerFingerprint)) == 1
+}
+
+// ConfirmationCode derives a numeric code of digits digits from a
+// shared secret, for two parties to compare, for example by reading
+// it out loud. The code is computed like an HOTP code (RFC 4226):
+// HMAC-SHA256 with the shared secret as key over confirmationCodeLabel
+// ("tkeyx25519 confirmation code"), dynamically truncated to 31 bits,
+// modulo 10^digits, zero padded. digits is clamped to 1..9.
+//
+// A 6 digit code only gives a one in a million chance of a person in
+// the middle going unnoticed; compare SecretFingerprint for more.
+func ConfirmationCode(shared []byte, digits int) string {
+	if digits < 1 {
+		digits = 1
+	}
+	if digits > 9 {
+		digits = 9
+	}
+
+	mac := hmac.New(sha256.New, shared)
+	mac.Write([]byte(confirmationCodeLabel))
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fff_ffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
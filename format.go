@@ -0,0 +1,107 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+
+	"github.com/tillitis/tkeyclient"
+	"go.bug.st/serial"
+)
+
+// FormatError turns an error returned by this package into a short,
+// actionable message suitable for showing to the user of a CLI or
+// similar. Errors it does not recognize are returned verbatim
+// (err.Error()). The original error should still be logged, as the
+// message leaves out details.
+func FormatError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var statusErr *ResponseStatusNotOKError
+	if errors.As(err, &statusErr) {
+		switch statusErr.Code() {
+		case StatusTouchTimeout:
+			return "Touch not detected in time — touch the TKey when it blinks, and try again"
+		case StatusWrongCmdLen:
+			return "The TKey app did not understand the command — is the app version compatible with this program?"
+		}
+	}
+
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		switch timeoutErr.Phase {
+		case PhaseConnect:
+			return "Timed out connecting to the TKey — is it plugged in?"
+		case PhaseTouch:
+			return "Touch not detected in time — touch the TKey when it blinks, and try again"
+		default:
+			return "The TKey did not respond in time — is the X25519 app loaded? Try unplugging and reinserting the TKey"
+		}
+	}
+
+	if errors.Is(err, ErrSmallOrderPoint) {
+		return "The other party's public key is invalid (a small order point)"
+	}
+
+	if errors.Is(err, ErrZeroPeerKey) {
+		return "The other party's public key is invalid (all zeroes)"
+	}
+
+	if errors.Is(err, ErrSmallOrderPubKey) {
+		return "The public key is invalid (a small order point)"
+	}
+
+	if errors.Is(err, ErrAppVersionMismatch) {
+		return "The TKey app is not the required version — load the right app version and try again"
+	}
+
+	if errors.Is(err, ErrTouchUnsupported) {
+		return "This TKey app does not support requiring touch — update the app, or do without touch"
+	}
+
+	if errors.Is(err, ErrLikelySpeedMismatch) {
+		return "Garbled responses from the TKey — is the serial port speed right? The TKey uses 62500 bps"
+	}
+
+	if errors.Is(err, ErrFrameCorrupt) {
+		return "Garbled response from the TKey — try again, or try another USB port or cable"
+	}
+
+	if errors.Is(err, ErrTouchPolicyConflict) {
+		return "The identity's touch setting does not match its key or the TKey app — check the identity's configuration"
+	}
+
+	if errors.Is(err, ErrUnexpectedPubKey) {
+		return "The TKey does not have the expected key — is it the right TKey, with the right app?"
+	}
+
+	if errors.Is(err, ErrFrameDesync) {
+		return "Lost track of the responses from the TKey — unplug and reinsert it, and try again"
+	}
+
+	if errors.Is(err, ErrDeviceWedged) {
+		return "The TKey stopped responding — unplug and reinsert it, and try again"
+	}
+
+	if errors.Is(err, ErrDeviceChanged) {
+		return "This is not the TKey used before — plug in the right TKey, and start over"
+	}
+
+	if errors.Is(err, ErrBusy) {
+		return "The TKey is busy with too many requests — try again later"
+	}
+
+	if errors.Is(err, tkeyclient.ErrResponseStatusNotOK) {
+		return "The TKey refused the command — is the X25519 app loaded, rather than another app or the firmware?"
+	}
+
+	var portErr *serial.PortError
+	if errors.As(err, &portErr) && portErr.Code() == serial.PortClosed {
+		return "The connection to the TKey is closed — was it unplugged?"
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// ErrFrameCorrupt is returned when a response frame fails the sanity
+// checks of verifyFrame, or something other than the expected frame
+// was read.
+var ErrFrameCorrupt = errors.New("response frame corrupt")
+
+// ErrFrameDesync is returned when using WithFrameResync, if the
+// response frames are still out of sync with the commands after the
+// resync attempts.
+var ErrFrameDesync = errors.New("response frames out of sync")
+
+// ErrLikelySpeedMismatch is returned by Warmup when the responses are
+// persistently garbled, which is what a wrong serial port speed looks
+// like.
+var ErrLikelySpeedMismatch = errors.New("responses garbled, serial port speed likely wrong")
+
+// speedProbeAttempts is the number of times Warmup tries to get the
+// name and version, before concluding that the speed is wrong.
+const speedProbeAttempts = 3
+
+// isGarbledFrame tells whether err, from ReadFrame, is due to reading
+// something that is not the expected frame, rather than failing I/O,
+// a timeout, or a not-OK response.
+func isGarbledFrame(err error) bool {
+	if err == nil || isReadTimeout(err) || errors.Is(err, tkeyclient.ErrResponseStatusNotOK) {
+		return false
+	}
+
+	return !isIOError(err)
+}
+
+// isIOError tells whether err is, or wraps, an error from ReadFrame
+// due to failing I/O on the connection. tkeyclient does not tell its
+// errors apart by type, but its I/O errors are the only ones starting
+// with "Read: " or "ReadFull: ".
+func isIOError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		msg := err.Error()
+		if strings.HasPrefix(msg, "Read: ") || strings.HasPrefix(msg, "ReadFull: ") {
+			return true
+		}
+	}
+	return false
+}
+
+// WithFrameResync makes X25519 detect a stale response left over from
+// an earlier command, such as one that timed out, and get back in
+// sync. Each command is then sent with the next frame id (1, 2, 3,
+// and around again) instead of always 2, so a response with the
+// wrong id is known to be stale. On that, the connection is drained
+// (see Drain) and the command sent again with a fresh id, up to
+// attempts times (1 if attempts is less than 1). If the response is
+// still stale, the command fails with ErrFrameDesync.
+//
+// Note that resending DoECDH with requireTouch requires touch again,
+// and that draining resets the read timeout, so the resent command
+// waits for the response without one. The default is no resync.
+func WithFrameResync(attempts int) Option {
+	return func(o *options) {
+		if attempts < 1 {
+			attempts = 1
+		}
+		o.frameResync = attempts
+	}
+}
+
+// frameID returns the frame id to use for the next command, see
+// WithFrameResync.
+func (x X25519) frameID() int {
+	if x.opts.frameResync == 0 {
+		return 2
+	}
+
+	x.st.mu.Lock()
+	defer x.st.mu.Unlock()
+
+	x.st.frameID = x.st.frameID%3 + 1
+
+	return x.st.frameID
+}
+
+// isStaleFrame tells whether reading a response to a frame with id
+// failed with err because the frame read, with header hdr, had
+// another id.
+func isStaleFrame(err error, hdr tkeyclient.FramingHdr, id int) bool {
+	if err == nil || isReadTimeout(err) || hdr == (tkeyclient.FramingHdr{}) {
+		return false
+	}
+
+	return hdr.ID != byte(id)
+}
+
+// verifyFrame checks that the response frame rx, including the header
+// byte, is the rsp frame it should be: its header has the reserved bit
+// clear, the frame id we sent, and the endpoint and length of rsp,
+// the frame is as long as the header says, and its response code is
+// that of rsp.
+//
+// The framing protocol has no checksum, so this is the only integrity
+// check possible. tkeyclient checks most of this too, but we do not
+// want a corrupted response to ever become a wrong shared secret, and
+// the connection may not be tkeyclient's.
+func verifyFrame(rx []byte, rsp tkeyclient.Cmd, id int) error {
+	if len(rx) < 2 {
+		return fmt.Errorf("%w: %d bytes", ErrFrameCorrupt, len(rx))
+	}
+
+	if rx[0]&0b1000_0000 != 0 {
+		return fmt.Errorf("%w: reserved bit set in header 0x%02x", ErrFrameCorrupt, rx[0])
+	}
+
+	hdr := parseFramingHdr(rx[0])
+	switch {
+	case hdr.ID != byte(id):
+		return fmt.Errorf("%w: frame id %d, expected %d", ErrFrameCorrupt, hdr.ID, id)
+	case hdr.Endpoint != rsp.Endpoint():
+		return fmt.Errorf("%w: endpoint %d, expected %d", ErrFrameCorrupt, hdr.Endpoint, rsp.Endpoint())
+	case hdr.CmdLen != rsp.CmdLen():
+		return fmt.Errorf("%w: length %d bytes, expected %d", ErrFrameCorrupt, hdr.CmdLen.Bytelen(), rsp.CmdLen().Bytelen())
+	case len(rx) != 1+hdr.CmdLen.Bytelen():
+		return fmt.Errorf("%w: frame is %d bytes, header says %d", ErrFrameCorrupt, len(rx), 1+hdr.CmdLen.Bytelen())
+	case rx[1] != rsp.Code():
+		return fmt.Errorf("%w: response code 0x%02x, expected 0x%02x", ErrFrameCorrupt, rx[1], rsp.Code())
+	}
+
+	return nil
+}
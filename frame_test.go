@@ -0,0 +1,56 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestFrameResync(t *testing.T) {
+	var userSecret [UserSecretSize]byte
+
+	want, err := newX25519(&fakeDevice{cdi: 1}, nil).GetPubKey("test", userSecret, false)
+	if err != nil {
+		t.Fatalf("GetPubKey: %v", err)
+	}
+
+	x := newX25519(&fakeDevice{cdi: 1, stale: 1}, []Option{WithFrameResync(1)})
+
+	got, err := x.GetPubKey("test", userSecret, false)
+	if err != nil {
+		t.Fatalf("GetPubKey after stale frame: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("GetPubKey after stale frame: got %x, want %x", got, want)
+	}
+
+	// Back in sync, the next command needs no resync
+	if _, err = x.GetPubKey("test", userSecret, false); err != nil {
+		t.Fatalf("GetPubKey after resync: %v", err)
+	}
+}
+
+func TestFrameResyncDesync(t *testing.T) {
+	var userSecret [UserSecretSize]byte
+
+	x := newX25519(&fakeDevice{cdi: 1, stale: 2}, []Option{WithFrameResync(1)})
+
+	_, err := x.GetPubKey("test", userSecret, false)
+	if !errors.Is(err, ErrFrameDesync) {
+		t.Fatalf("GetPubKey: got %v, want ErrFrameDesync", err)
+	}
+}
+
+func TestFrameStaleWithoutResync(t *testing.T) {
+	var userSecret [UserSecretSize]byte
+
+	x := newX25519(&fakeDevice{cdi: 1, stale: 1}, nil)
+
+	_, err := x.GetPubKey("test", userSecret, false)
+	if err == nil || errors.Is(err, ErrFrameDesync) {
+		t.Fatalf("GetPubKey: got %v, want plain frame error", err)
+	}
+}
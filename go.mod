@@ -4,13 +4,13 @@ go 1.19
 
 require (
 	github.com/tillitis/tkeyclient v1.0.0
+	go.bug.st/serial v1.6.2
 	golang.org/x/crypto v0.26.0
 )
 
 require (
 	github.com/creack/goselect v0.1.2 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	go.bug.st/serial v1.6.2 // indirect
 	golang.org/x/sys v0.23.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,231 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// The HPKE (RFC 9180) algorithm identifiers implemented by
+// SetupHPKESender and SetupHPKEReceiver. Only the base mode (0x00) is
+// implemented.
+const (
+	HPKEKEMX25519HKDFSHA256  = 0x0020 // DHKEM(X25519, HKDF-SHA256)
+	HPKEKDFHKDFSHA256        = 0x0001 // HKDF-SHA256
+	HPKEAEADChaCha20Poly1305 = 0x0003 // ChaCha20Poly1305
+)
+
+const (
+	hpkeModeBase = 0x00
+	hpkeNsecret  = 32
+	hpkeNh       = 32
+	hpkeVersion  = "HPKE-v1"
+)
+
+var (
+	hpkeKEMSuiteID = []byte{'K', 'E', 'M', 0x00, 0x20}
+	hpkeSuiteID    = []byte{'H', 'P', 'K', 'E', 0x00, 0x20, 0x00, 0x01, 0x00, 0x03}
+)
+
+// HPKEContext is an HPKE encryption context, see SetupHPKESender and
+// SetupHPKEReceiver. A sender's context can only Seal, and a
+// receiver's only Open.
+type HPKEContext struct {
+	aead           cipher.AEAD
+	baseNonce      []byte
+	seq            uint64
+	exporterSecret []byte
+	sender         bool
+}
+
+// SetupHPKESender sets up HPKE base mode encryption to the receiver
+// with public key pkR, which can be a public key from GetPubKey. The
+// sender uses an ephemeral key generated in software. It returns the
+// encapsulated key enc, which must be sent along to the receiver, and
+// the context to Seal messages with. Of opts, only WithRand applies.
+func SetupHPKESender(pkR []byte, info []byte, opts ...Option) ([]byte, *HPKEContext, error) {
+	if len(pkR) != 32 {
+		return nil, nil, fmt.Errorf("wrong public key length %d", len(pkR))
+	}
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	skE, enc, err := generateEphemeral(o.random())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer wipe(skE)
+
+	dh, err := curve25519.X25519(skE, pkR)
+	if err != nil {
+		return nil, nil, fmt.Errorf("X25519: %w", err)
+	}
+
+	ctx, err := hpkeKeySchedule(hpkeSharedSecret(dh, enc, pkR), info, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return enc, ctx, nil
+}
+
+// SetupHPKEReceiver sets up HPKE base mode decryption of messages
+// sent using enc, the encapsulated key from SetupHPKESender. The
+// receiver's private key is the one on the TKey for domainString,
+// userSecret, and requireTouch (see GetPubKey); DoECDH is done with
+// enc as theirPubKey.
+func (x X25519) SetupHPKEReceiver(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, enc []byte, info []byte, opts ...Option) (*HPKEContext, error) {
+	var pkE [32]byte
+	if len(enc) != len(pkE) {
+		return nil, fmt.Errorf("wrong encapsulated key length %d", len(enc))
+	}
+	copy(pkE[:], enc)
+
+	pkR, err := x.GetPubKey(domainString, userSecret, requireTouch, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	dh, err := x.DoECDH(domainString, userSecret, requireTouch, pkE, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return hpkeKeySchedule(hpkeSharedSecret(dh, enc, pkR), info, false)
+}
+
+// Seal encrypts and authenticates plaintext, and authenticates aad.
+func (c *HPKEContext) Seal(aad, plaintext []byte) ([]byte, error) {
+	if !c.sender {
+		return nil, errors.New("receiver context can not Seal")
+	}
+
+	nonce, err := c.nonce()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := c.aead.Seal(nil, nonce, plaintext, aad)
+	c.seq++
+
+	return ciphertext, nil
+}
+
+// Open decrypts ciphertext and authenticates it and aad. Messages
+// must be opened in the order they were sealed. A message that fails
+// to open does not count, so the next one can still be opened.
+func (c *HPKEContext) Open(aad, ciphertext []byte) ([]byte, error) {
+	if c.sender {
+		return nil, errors.New("sender context can not Open")
+	}
+
+	nonce, err := c.nonce()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("Open: %w", err)
+	}
+	c.seq++
+
+	return plaintext, nil
+}
+
+// Export derives a secret of length bytes from the context, as the
+// HPKE secret export interface.
+func (c *HPKEContext) Export(exporterContext []byte, length int) ([]byte, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("negative export length %d", length)
+	}
+	if length > 255*hpkeNh {
+		return nil, fmt.Errorf("export length %d too large", length)
+	}
+
+	return hpkeLabeledExpand(hpkeSuiteID, c.exporterSecret, "sec", exporterContext, length), nil
+}
+
+// nonce returns the nonce for the current sequence number, which the
+// caller increments once the message is sealed or opened.
+func (c *HPKEContext) nonce() ([]byte, error) {
+	if c.seq == ^uint64(0) {
+		return nil, errors.New("message limit reached")
+	}
+
+	nonce := make([]byte, len(c.baseNonce))
+	copy(nonce, c.baseNonce)
+	var seq [8]byte
+	binary.BigEndian.PutUint64(seq[:], c.seq)
+	for i, b := range seq {
+		nonce[len(nonce)-len(seq)+i] ^= b
+	}
+
+	return nonce, nil
+}
+
+// hpkeSharedSecret is DHKEM's ExtractAndExpand, with kem_context
+// being enc followed by pkR.
+func hpkeSharedSecret(dh, enc, pkR []byte) []byte {
+	kemContext := append(append([]byte{}, enc...), pkR...)
+	eaePRK := hpkeLabeledExtract(hpkeKEMSuiteID, nil, "eae_prk", dh)
+
+	return hpkeLabeledExpand(hpkeKEMSuiteID, eaePRK, "shared_secret", kemContext, hpkeNsecret)
+}
+
+func hpkeKeySchedule(sharedSecret []byte, info []byte, sender bool) (*HPKEContext, error) {
+	pskIDHash := hpkeLabeledExtract(hpkeSuiteID, nil, "psk_id_hash", nil)
+	infoHash := hpkeLabeledExtract(hpkeSuiteID, nil, "info_hash", info)
+	keyScheduleContext := append(append([]byte{hpkeModeBase}, pskIDHash...), infoHash...)
+
+	secret := hpkeLabeledExtract(hpkeSuiteID, sharedSecret, "secret", nil)
+
+	key := hpkeLabeledExpand(hpkeSuiteID, secret, "key", keyScheduleContext, chacha20poly1305.KeySize)
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("chacha20poly1305.New: %w", err)
+	}
+
+	return &HPKEContext{
+		aead:           aead,
+		baseNonce:      hpkeLabeledExpand(hpkeSuiteID, secret, "base_nonce", keyScheduleContext, chacha20poly1305.NonceSize),
+		exporterSecret: hpkeLabeledExpand(hpkeSuiteID, secret, "exp", keyScheduleContext, hpkeNh),
+		sender:         sender,
+	}, nil
+}
+
+func hpkeLabeledExtract(suiteID []byte, salt []byte, label string, ikm []byte) []byte {
+	labeledIKM := append([]byte(hpkeVersion), suiteID...)
+	labeledIKM = append(labeledIKM, label...)
+	labeledIKM = append(labeledIKM, ikm...)
+
+	return hkdf.Extract(sha256.New, labeledIKM, salt)
+}
+
+func hpkeLabeledExpand(suiteID []byte, prk []byte, label string, info []byte, length int) []byte {
+	labeledInfo := binary.BigEndian.AppendUint16(nil, uint16(length))
+	labeledInfo = append(labeledInfo, hpkeVersion...)
+	labeledInfo = append(labeledInfo, suiteID...)
+	labeledInfo = append(labeledInfo, label...)
+	labeledInfo = append(labeledInfo, info...)
+
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, labeledInfo), out); err != nil {
+		// Only happens if length is larger than 255*hpkeNh bytes
+		panic(err)
+	}
+
+	return out
+}
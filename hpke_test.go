@@ -0,0 +1,164 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// RFC 9180 A.2.1: DHKEM(X25519, HKDF-SHA256), HKDF-SHA256,
+// ChaCha20Poly1305, base mode.
+const (
+	hpkeTestInfo           = "4f6465206f6e2061204772656369616e2055726e"
+	hpkeTestSkEm           = "f4ec9b33b792c372c1d2c2063507b684ef925b8c75a42dbcbf57d63ccd381600"
+	hpkeTestSkRm           = "8057991eef8f1f1af18f4a9491d16a1ce333f695d4db8e38da75975c4478e0fb"
+	hpkeTestPkRm           = "4310ee97d88cc1f088a5576c77ab0cf5c3ac797f3d95139c6c84b5429c59662a"
+	hpkeTestEnc            = "1afa08d3dec047a643885163f1180476fa7ddb54c6a8029ea33f95796bf2ac4a"
+	hpkeTestSharedSecret   = "0bbe78490412b4bbea4812666f7916932b828bba79942424abb65244930d69a7"
+	hpkeTestBaseNonce      = "5c4d98150661b848853b547f"
+	hpkeTestExporterSecret = "a3b010d4994890e2c6968a36f64470d3c824c8f5029942feb11e7a74b2921922"
+	hpkeTestPt             = "4265617574792069732074727574682c20747275746820626561757479"
+)
+
+var hpkeTestEncryptions = []struct {
+	aad, ct string
+}{
+	{"436f756e742d30", "1c5250d8034ec2b784ba2cfd69dbdb8af406cfe3ff938e131f0def8c8b60b4db21993c62ce81883d2dd1b51a28"},
+	{"436f756e742d31", "6b53c051e4199c518de79594e1c4ab18b96f081549d45ce015be002090bb119e85285337cc95ba5f59992dc98c"},
+}
+
+var hpkeTestExports = []struct {
+	context, value string
+}{
+	{"", "4bbd6243b8bb54cec311fac9df81841b6fd61f56538a775e7c80a9f40160606e"},
+	{"00", "8c1df14732580e5501b00f82b10a1647b40713191b7c1240ac80e2b68808ba69"},
+	{"54657374436f6e74657874", "5acb09211139c43b3090489a9da433e8a30ee7188ba8b0a9a1ccf0c229283e53"},
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("bad test hex %q", s)
+	}
+
+	return b
+}
+
+// hpkeTestContexts sets up the sender and receiver of the RFC 9180
+// A.2.1 vector, the receiver using the private key in software
+// instead of on a TKey.
+func hpkeTestContexts(t *testing.T) (sender, receiver *HPKEContext) {
+	t.Helper()
+
+	info := mustHex(t, hpkeTestInfo)
+	pkR := mustHex(t, hpkeTestPkRm)
+
+	enc, sender, err := SetupHPKESender(pkR, info, WithRand(bytes.NewReader(mustHex(t, hpkeTestSkEm))))
+	if err != nil {
+		t.Fatalf("SetupHPKESender: %v", err)
+	}
+	if want := mustHex(t, hpkeTestEnc); !bytes.Equal(enc, want) {
+		t.Fatalf("enc: got %x, want %x", enc, want)
+	}
+
+	dh, err := curve25519.X25519(mustHex(t, hpkeTestSkRm), enc)
+	if err != nil {
+		t.Fatalf("X25519: %v", err)
+	}
+	sharedSecret := hpkeSharedSecret(dh, enc, pkR)
+	if want := mustHex(t, hpkeTestSharedSecret); !bytes.Equal(sharedSecret, want) {
+		t.Fatalf("shared_secret: got %x, want %x", sharedSecret, want)
+	}
+
+	receiver, err = hpkeKeySchedule(sharedSecret, info, false)
+	if err != nil {
+		t.Fatalf("hpkeKeySchedule: %v", err)
+	}
+
+	return sender, receiver
+}
+
+func TestHPKEVector(t *testing.T) {
+	sender, receiver := hpkeTestContexts(t)
+
+	for name, c := range map[string]*HPKEContext{"sender": sender, "receiver": receiver} {
+		if want := mustHex(t, hpkeTestBaseNonce); !bytes.Equal(c.baseNonce, want) {
+			t.Errorf("%s base_nonce: got %x, want %x", name, c.baseNonce, want)
+		}
+		if want := mustHex(t, hpkeTestExporterSecret); !bytes.Equal(c.exporterSecret, want) {
+			t.Errorf("%s exporter_secret: got %x, want %x", name, c.exporterSecret, want)
+		}
+	}
+
+	pt := mustHex(t, hpkeTestPt)
+	for i, e := range hpkeTestEncryptions {
+		aad, want := mustHex(t, e.aad), mustHex(t, e.ct)
+
+		ct, err := sender.Seal(aad, pt)
+		if err != nil {
+			t.Fatalf("Seal %d: %v", i, err)
+		}
+		if !bytes.Equal(ct, want) {
+			t.Errorf("Seal %d: got %x, want %x", i, ct, want)
+		}
+
+		got, err := receiver.Open(aad, want)
+		if err != nil {
+			t.Fatalf("Open %d: %v", i, err)
+		}
+		if !bytes.Equal(got, pt) {
+			t.Errorf("Open %d: got %x, want %x", i, got, pt)
+		}
+	}
+
+	for _, e := range hpkeTestExports {
+		want := mustHex(t, e.value)
+		for name, c := range map[string]*HPKEContext{"sender": sender, "receiver": receiver} {
+			got, err := c.Export(mustHex(t, e.context), len(want))
+			if err != nil {
+				t.Fatalf("%s Export %q: %v", name, e.context, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s Export %q: got %x, want %x", name, e.context, got, want)
+			}
+		}
+	}
+}
+
+func TestHPKEOpenAfterFailure(t *testing.T) {
+	_, receiver := hpkeTestContexts(t)
+
+	e := hpkeTestEncryptions[0]
+	aad, ct := mustHex(t, e.aad), mustHex(t, e.ct)
+
+	forged := append([]byte(nil), ct...)
+	forged[0] ^= 1
+	if _, err := receiver.Open(aad, forged); err == nil {
+		t.Fatal("Open of forged ciphertext: got no error")
+	}
+
+	got, err := receiver.Open(aad, ct)
+	if err != nil {
+		t.Fatalf("Open after failure: %v", err)
+	}
+	if want := mustHex(t, hpkeTestPt); !bytes.Equal(got, want) {
+		t.Errorf("Open after failure: got %x, want %x", got, want)
+	}
+}
+
+func TestHPKEExportLength(t *testing.T) {
+	sender, _ := hpkeTestContexts(t)
+
+	for _, length := range []int{-1, 255*hpkeNh + 1} {
+		if _, err := sender.Export(nil, length); err == nil {
+			t.Errorf("Export of %d bytes: got no error", length)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// Identity describes a key pair on a TKey, as used by
+// ExportIdentities and ImportIdentities. The key pair is determined
+// by the TKey's CDI (unique per TKey and device app), Domain,
+// RequireTouch, and the userSecret.
+type Identity struct {
+	// Label is a name for the identity, for the user.
+	Label string `json:"label"`
+	// Domain is the domainString passed to GetPubKey.
+	Domain string `json:"domain"`
+	// RequireTouch is the requireTouch passed to GetPubKey.
+	RequireTouch bool `json:"require_touch"`
+	// PubKey is the public key, if known.
+	PubKey []byte `json:"pub_key,omitempty"`
+	// UserSecret is the userSecret, if known. It is nil unless
+	// included explicitly, see ExportIdentitiesWithSecrets.
+	UserSecret []byte `json:"user_secret,omitempty"`
+}
+
+// ErrTouchPolicyConflict is returned by ReconcileIdentityTouch when
+// an identity's RequireTouch does not agree with its public key, or
+// with the device app.
+var ErrTouchPolicyConflict = errors.New("touch policy conflict")
+
+// identityTagLabel is prepended to the hashed data in IdentityTag.
+const identityTagLabel = "tkeyx25519 identity tag"
+
+// identityShardLabel is prepended to the public key when hashing it
+// in IdentityShard.
+const identityShardLabel = "tkeyx25519 identity shard"
+
+// counterSeedLabel is prepended to the hashed data in CounterSeed.
+const counterSeedLabel = "tkeyx25519 counter seed"
+
+// IdentityTagSize is the size of a tag from IdentityTag.
+const IdentityTagSize = blake2s.Size
+
+// IdentityTag returns a tag identifying the key pair with public key
+// pub, for domainString and requireTouch, suitable as a key when
+// storing identities in a database. It is the blake2s-256 hash of
+// identityTagLabel ("tkeyx25519 identity tag"), the 32 byte domain as
+// sent to the device app (see GetPubKey), the touch byte (1 if
+// requireTouch, else 0), and the public key.
+//
+// The userSecret is not part of the tag, so the tag reveals nothing
+// that the public key and domain do not. Two identities differing
+// only in userSecret have different public keys, and so different
+// tags.
+func IdentityTag(domainString string, requireTouch bool, pub []byte) []byte {
+	domain := normalizeDomain(domainString)
+
+	data := append([]byte(identityTagLabel), domain[:]...)
+	data = append(data, touchByte(requireTouch))
+	data = append(data, pub...)
+
+	sum := blake2s.Sum256(data)
+
+	return sum[:]
+}
+
+// IdentityShard maps the public key pub to one of shards shards,
+// numbered from 0, for services spreading identities over backends.
+// The same public key always gives the same shard. It is the first 8
+// bytes of the blake2s-256 hash of identityShardLabel ("tkeyx25519
+// identity shard") followed by pub, read as a big-endian uint64,
+// modulo shards. It panics if shards is less than 1.
+func IdentityShard(pub []byte, shards int) int {
+	if shards < 1 {
+		panic(fmt.Sprintf("IdentityShard: %d shards", shards))
+	}
+
+	sum := blake2s.Sum256(append([]byte(identityShardLabel), pub...))
+
+	return int(binary.BigEndian.Uint64(sum[:8]) % uint64(shards))
+}
+
+// CounterSeed derives a starting value for a monotonic counter, such as
+// a message sequence number, for the identity with public key pub and
+// domainString. The same identity always gives the same seed, and
+// different identities unrelated seeds. It is the first 8 bytes of
+// the blake2s-256 hash of counterSeedLabel ("tkeyx25519 counter
+// seed"), the 32 byte domain as sent to the device app (see
+// GetPubKey), and pub, read as a big-endian uint64, with the most
+// significant bit cleared. The seed is thus below 2^63, leaving at
+// least 2^63 increments before the counter wraps.
+//
+// The seed is not secret, since anyone knowing pub and domainString
+// can compute it.
+func CounterSeed(pub []byte, domainString string) uint64 {
+	domain := normalizeDomain(domainString)
+
+	data := append([]byte(counterSeedLabel), domain[:]...)
+	data = append(data, pub...)
+	sum := blake2s.Sum256(data)
+
+	return binary.BigEndian.Uint64(sum[:8]) &^ (1 << 63)
+}
+
+// DoECDHWithIdentity does DoECDH using the key on the TKey described by
+// local, whose UserSecret must be set, with remotePub, the static
+// public key of the peer (which may be another TKey's key from
+// GetPubKey). It is DoECDH with the local and remote inputs clearly
+// apart. Both sides can confirm they got the same shared secret by
+// exchanging their SecretFingerprint, see ConfirmSharedSecret. To
+// check RequireTouch of local first, see ReconcileIdentityTouch.
+func (x X25519) DoECDHWithIdentity(local Identity, remotePub []byte, opts ...Option) ([]byte, error) {
+	if len(local.UserSecret) != UserSecretSize {
+		return nil, fmt.Errorf("local identity has no user secret (%d bytes)", len(local.UserSecret))
+	}
+	if len(remotePub) != 32 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrPubKeyLength, len(remotePub))
+	}
+	if local.PubKey != nil && bytes.Equal(local.PubKey, remotePub) {
+		return nil, errors.New("remote public key is the local identity's own")
+	}
+
+	var userSecret [UserSecretSize]byte
+	copy(userSecret[:], local.UserSecret)
+	defer wipe(userSecret[:])
+
+	var theirPubKey [32]byte
+	copy(theirPubKey[:], remotePub)
+
+	return x.DoECDH(local.Domain, userSecret, local.RequireTouch, theirPubKey, opts...)
+}
+
+// ReconcileIdentityTouch checks that id.RequireTouch can be used, with
+// the device app and with id.PubKey, before using id for DoECDH.
+// Since requireTouch is part of the key derivation, a key got with
+// touch on must be used with touch on, and the other way around. The
+// rules are:
+//
+//   - If id.RequireTouch is set, the device app must enforce touch. A
+//     device app reporting (see GetCapabilities) that it does not
+//     gives ErrTouchPolicyConflict. One that does not report
+//     capabilities is assumed to enforce touch.
+//   - If id.PubKey and id.UserSecret are set, the public key for
+//     id.RequireTouch must be id.PubKey. If instead the one for the
+//     opposite requireTouch is, the identity was set up with the other
+//     touch setting, and ErrTouchPolicyConflict is returned. If
+//     neither is, ErrUnexpectedPubKey is returned.
+//
+// Getting the public keys never requires touch.
+func (x X25519) ReconcileIdentityTouch(id Identity, opts ...Option) error {
+	x = x.with(opts)
+
+	x, release, err := x.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if id.RequireTouch {
+		caps, err := x.cachedCapabilities()
+		if err != nil {
+			return err
+		}
+		if caps.Known && !caps.TouchEnforced {
+			return fmt.Errorf("%w: identity requires touch, which the device app does not enforce", ErrTouchPolicyConflict)
+		}
+	}
+
+	if id.PubKey == nil || len(id.UserSecret) != UserSecretSize {
+		return nil
+	}
+
+	var userSecret [UserSecretSize]byte
+	copy(userSecret[:], id.UserSecret)
+	defer wipe(userSecret[:])
+
+	pub, err := x.GetPubKey(id.Domain, userSecret, id.RequireTouch)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(pub, id.PubKey) {
+		return nil
+	}
+
+	pub, err = x.GetPubKey(id.Domain, userSecret, !id.RequireTouch)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(pub, id.PubKey) {
+		return fmt.Errorf("%w: public key was got with requireTouch %v, identity has %v", ErrTouchPolicyConflict, !id.RequireTouch, id.RequireTouch)
+	}
+
+	return fmt.Errorf("%w for identity %q", ErrUnexpectedPubKey, id.Label)
+}
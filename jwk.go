@@ -0,0 +1,80 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// jwk is an X25519 public key as a JSON Web Key, see RFC 7517 and
+// RFC 8037. The field order makes the JSON stable.
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// PubKeyJWK returns pub as a JSON Web Key (RFC 7517), with key type
+// "OKP" and curve "X25519" (RFC 8037), the key base64url encoded
+// without padding in "x", and "use" set to "enc", since X25519 keys
+// are for key agreement. The key ID kid is included if not empty. The
+// output is compact JSON with the fields always in the same order, so
+// the same key and kid always give the same bytes.
+func PubKeyJWK(pub []byte, kid string) ([]byte, error) {
+	if len(pub) != 32 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrPubKeyLength, len(pub))
+	}
+
+	b, err := json.Marshal(jwk{
+		Kty: "OKP",
+		Crv: "X25519",
+		X:   base64.RawURLEncoding.EncodeToString(pub),
+		Use: "enc",
+		Kid: kid,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Marshal: %w", err)
+	}
+
+	return b, nil
+}
+
+// ParsePubKeyJWK parses a JSON Web Key as written by PubKeyJWK,
+// returning the public key and key ID (empty if there is none). The
+// key type must be "OKP" and the curve "X25519", and "use", if
+// present, must be "enc". Private keys ("d") are rejected.
+func ParsePubKeyJWK(b []byte) (pub []byte, kid string, err error) {
+	var k struct {
+		jwk
+		D *string `json:"d"`
+	}
+	if err = json.Unmarshal(b, &k); err != nil {
+		return nil, "", fmt.Errorf("Unmarshal: %w", err)
+	}
+
+	if k.Kty != "OKP" || k.Crv != "X25519" {
+		return nil, "", fmt.Errorf("not an X25519 key (kty %q, crv %q)", k.Kty, k.Crv)
+	}
+	if k.Use != "" && k.Use != "enc" {
+		return nil, "", fmt.Errorf("unexpected use %q", k.Use)
+	}
+	if k.D != nil {
+		return nil, "", errors.New("JWK holds a private key")
+	}
+
+	pub, err = base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, "", fmt.Errorf("decoding x: %w", err)
+	}
+	if len(pub) != 32 {
+		return nil, "", fmt.Errorf("%w: %d bytes", ErrPubKeyLength, len(pub))
+	}
+
+	return pub, k.Kid, nil
+}
@@ -0,0 +1,174 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// HKDF info labels used by the key derivation helpers.
+const (
+	NonceInfo = "tkeyx25519 nonce"
+	EncInfo   = "tkeyx25519 enc"
+	MACInfo   = "tkeyx25519 mac"
+
+	InitiatorToResponderInfo = "tkeyx25519 initiator to responder"
+	ResponderToInitiatorInfo = "tkeyx25519 responder to initiator"
+
+	FileKeyInfo = "tkeyx25519 file key"
+)
+
+// separatedSecretLabel is prepended to the hashed data in
+// DomainSeparatedSecret.
+const separatedSecretLabel = "tkeyx25519 domain separated secret"
+
+// hkdfSaltLabel is prepended to the hashed data in HKDFSalt.
+const hkdfSaltLabel = "tkeyx25519 hkdf salt"
+
+// maxHKDFSize is the most HKDF-SHA256 can derive.
+const maxHKDFSize = 255 * sha256.Size
+
+// SplitKeySize is the size of the keys returned by SplitKeys.
+const SplitKeySize = 32
+
+// DeriveNonce returns a 12-byte AEAD nonce (as used by for example
+// ChaCha20-Poly1305 and AES-GCM) for message number counter, sent
+// under a key derived from shared. A base nonce is derived using
+// HKDF-SHA256 with shared as input keying material, no salt, and
+// NonceInfo as info. The counter, big-endian, is then XORed into the
+// last 8 bytes of the base nonce (like in RFC 9180).
+//
+// Distinct counters give distinct nonces. It is up to the caller to
+// never use the same counter twice with the same shared secret (and
+// key), for example by keeping a message counter that is persisted
+// and only ever increased.
+func DeriveNonce(shared []byte, counter uint64) []byte {
+	nonce := hkdfSHA256(shared, nil, NonceInfo, chacha20poly1305.NonceSize)
+
+	var ctr [8]byte
+	binary.BigEndian.PutUint64(ctr[:], counter)
+	for i, b := range ctr {
+		nonce[len(nonce)-len(ctr)+i] ^= b
+	}
+
+	return nonce
+}
+
+// SplitKeys derives two independent keys from shared, for protocols
+// doing encrypt-then-MAC. Both are derived using HKDF-SHA256 with
+// shared as input keying material and no salt; encKey with EncInfo
+// and macKey with MACInfo as info. Both are SplitKeySize bytes.
+func SplitKeys(shared []byte) (encKey, macKey []byte) {
+	encKey = hkdfSHA256(shared, nil, EncInfo, SplitKeySize)
+	macKey = hkdfSHA256(shared, nil, MACInfo, SplitKeySize)
+
+	return encKey, macKey
+}
+
+// DirectionalKeys derives one key for each direction of a channel
+// from shared, so that a message sent by one side can never be
+// reflected back as if sent by the other. Both are derived using
+// HKDF-SHA256 with shared as input keying material and no salt; the
+// key for messages from the initiator to the responder with
+// InitiatorToResponderInfo, and the one for the other direction with
+// ResponderToInitiatorInfo, as info. Both are SplitKeySize bytes.
+//
+// The initiator is the side that started the exchange; the two sides
+// must agree on which one that is, and pass initiator accordingly.
+// The initiator's sendKey is then the responder's recvKey, and the
+// other way around.
+func DirectionalKeys(shared []byte, initiator bool) (sendKey, recvKey []byte) {
+	i2r := hkdfSHA256(shared, nil, InitiatorToResponderInfo, SplitKeySize)
+	r2i := hkdfSHA256(shared, nil, ResponderToInitiatorInfo, SplitKeySize)
+
+	if initiator {
+		return i2r, r2i
+	}
+	return r2i, i2r
+}
+
+// FileKeySize is the size of a key from FileKey.
+const FileKeySize = 32
+
+// FileKey derives a key for the file identified by fileID from
+// shared, so that many files can be encrypted to the same recipient
+// with a single ECDH, and so a single touch, each under a key of its
+// own. It is derived using HKDF-SHA256 with shared as input keying
+// material, no salt, and FileKeyInfo followed by fileID as info, and
+// is FileKeySize bytes.
+//
+// fileID must be unique per file for a given shared secret, such as a
+// random 16 byte value stored with the file; the same fileID gives the
+// same key, and a reused key may mean a reused nonce.
+func FileKey(shared []byte, fileID []byte) []byte {
+	return hkdfSHA256(shared, nil, FileKeyInfo+string(fileID), FileKeySize)
+}
+
+// DomainSeparatedSecret binds shared, a raw ECDH result, to the
+// protocol it is used in, so that the same shared secret used in two
+// protocols gives unrelated secrets. It is the blake2s-256 hash of
+// separatedSecretLabel ("tkeyx25519 domain separated secret"), the
+// length of protocolLabel as a big-endian uint32, protocolLabel, and
+// shared. Both sides must use the same protocolLabel, which should
+// name the protocol and its version, like "example.com chat v1".
+func DomainSeparatedSecret(shared []byte, protocolLabel string) []byte {
+	data := append([]byte(separatedSecretLabel), binary.BigEndian.AppendUint32(nil, uint32(len(protocolLabel)))...)
+	data = append(data, protocolLabel...)
+	data = append(data, shared...)
+	defer wipe(data)
+
+	sum := blake2s.Sum256(data)
+
+	return sum[:]
+}
+
+// HKDFSalt returns a salt bound to both parties' public keys, for
+// DeriveKey. Both sides get the same salt, whichever of them is local.
+// It is the blake2s-256 hash of hkdfSaltLabel ("tkeyx25519 hkdf
+// salt") followed by the two public keys (32 bytes each), the lower
+// one first, as compared by bytes.Compare (see SortPubKeys).
+func HKDFSalt(localPub, remotePub []byte) []byte {
+	lo, hi := localPub, remotePub
+	if bytes.Compare(lo, hi) > 0 {
+		lo, hi = hi, lo
+	}
+
+	data := append([]byte(hkdfSaltLabel), lo...)
+	data = append(data, hi...)
+	sum := blake2s.Sum256(data)
+
+	return sum[:]
+}
+
+// DeriveKey derives a size byte key from shared using HKDF-SHA256,
+// with salt (for example from HKDFSalt, or nil for none) and info. It
+// fails if size is more than HKDF-SHA256 can derive (8160 bytes).
+func DeriveKey(shared []byte, salt []byte, info string, size int) ([]byte, error) {
+	if size < 0 || size > maxHKDFSize {
+		return nil, fmt.Errorf("key size %d out of range", size)
+	}
+
+	return hkdfSHA256(shared, salt, info, size), nil
+}
+
+// hkdfSHA256 derives size bytes from secret using HKDF-SHA256.
+func hkdfSHA256(secret []byte, salt []byte, info string, size int) []byte {
+	out := make([]byte, size)
+
+	r := hkdf.New(sha256.New, secret, salt, []byte(info))
+	if _, err := io.ReadFull(r, out); err != nil {
+		// Only happens if size is larger than maxHKDFSize
+		panic(err)
+	}
+
+	return out
+}
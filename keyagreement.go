@@ -0,0 +1,254 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// KeyAgreementInfo is the HKDF info label used when deriving the
+// final keys of a KeyAgreement.
+const KeyAgreementInfo = "tkeyx25519 key agreement"
+
+// ErrHandshakeOrder is returned when a KeyAgreement step is done out
+// of order, or a second time.
+var ErrHandshakeOrder = errors.New("handshake step out of order")
+
+// Role is the role of a party in a KeyAgreement.
+type Role int
+
+const (
+	Initiator Role = iota
+	Responder
+)
+
+func (r Role) String() string {
+	switch r {
+	case Initiator:
+		return "initiator"
+	case Responder:
+		return "responder"
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// KeyAgreement guides an authenticated key exchange between two
+// parties, each with a static key on a TKey and an ephemeral key
+// generated in software. The steps are:
+//
+//  1. SendEphemeral, sending the returned key to the peer.
+//  2. ReceivePeerKeys, with the peer's static and ephemeral keys.
+//     Steps 1 and 2 can be done in either order.
+//  3. ECDH, which does DoECDH on the TKey, possibly requiring touch.
+//  4. DeriveKeys.
+//
+// MixTranscript can be called any time before DeriveKeys, and must be
+// called with the same data, in the same order, by both parties.
+//
+// The transcript is a SHA-256 hash chain over the mixed data, and the
+// static and ephemeral public keys of the initiator followed by those
+// of the responder. The ECDH step computes ee (ephemeral-ephemeral),
+// and es and se (each party's ephemeral with the other's static key;
+// the TKey does the one using our static key). The final keys are
+// derived with HKDF-SHA256 from ee, the initiator's ephemeral with
+// the responder's static, and the initiator's static with the
+// responder's ephemeral, in that order, with the transcript hash as
+// salt and KeyAgreementInfo as info. Only a party holding the static
+// private key the peer expects can derive the same keys.
+//
+// A KeyAgreement is not safe for concurrent use.
+type KeyAgreement struct {
+	x            X25519
+	role         Role
+	domain       string
+	userSecret   [UserSecretSize]byte
+	requireTouch bool
+
+	staticPub    []byte
+	ephemeral    []byte // Private key, wiped after ECDH
+	ephemeralPub []byte
+	peerStatic   []byte
+	peerEph      []byte
+
+	transcript []byte
+	secret     []byte // ee, es, se, wiped after DeriveKeys
+
+	sent, received, agreed, derived bool
+}
+
+// NewKeyAgreement starts a key exchange in role, using the key on the
+// TKey for domainString, userSecret, and requireTouch (see
+// GetPubKey) as our static key. Touch is only required in the ECDH
+// step.
+func (x X25519) NewKeyAgreement(role Role, domainString string, userSecret [UserSecretSize]byte, requireTouch bool, opts ...Option) (*KeyAgreement, error) {
+	x = x.with(opts)
+
+	if role != Initiator && role != Responder {
+		return nil, fmt.Errorf("unknown role %v", role)
+	}
+
+	staticPub, err := x.GetPubKey(domainString, userSecret, requireTouch)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyAgreement{
+		x:            x,
+		role:         role,
+		domain:       domainString,
+		userSecret:   userSecret,
+		requireTouch: requireTouch,
+		staticPub:    staticPub,
+		transcript:   make([]byte, sha256.Size),
+	}, nil
+}
+
+// StaticPubKey returns our static public key, which the peer needs to
+// know and trust beforehand.
+func (k *KeyAgreement) StaticPubKey() []byte {
+	return append([]byte(nil), k.staticPub...)
+}
+
+// SendEphemeral generates our ephemeral key, and returns its public
+// key to be sent to the peer.
+func (k *KeyAgreement) SendEphemeral() ([]byte, error) {
+	if k.sent || k.agreed {
+		return nil, fmt.Errorf("%w: SendEphemeral already done", ErrHandshakeOrder)
+	}
+
+	priv, pub, err := generateEphemeral(k.x.opts.random())
+	if err != nil {
+		return nil, err
+	}
+	k.ephemeral, k.ephemeralPub = priv, pub
+	k.sent = true
+
+	return append([]byte(nil), pub...), nil
+}
+
+// ReceivePeerKeys sets the peer's static public key, which must be
+// checked to be the expected one, and the ephemeral public key the
+// peer sent.
+func (k *KeyAgreement) ReceivePeerKeys(peerStatic, peerEphemeral []byte) error {
+	if k.received || k.agreed {
+		return fmt.Errorf("%w: ReceivePeerKeys already done", ErrHandshakeOrder)
+	}
+	if len(peerStatic) != 32 || len(peerEphemeral) != 32 {
+		return fmt.Errorf("wrong peer key lengths %d and %d", len(peerStatic), len(peerEphemeral))
+	}
+
+	k.peerStatic = append([]byte(nil), peerStatic...)
+	k.peerEph = append([]byte(nil), peerEphemeral...)
+	k.received = true
+
+	return nil
+}
+
+// MixTranscript mixes data, like a protocol name or negotiated
+// parameters, into the transcript.
+func (k *KeyAgreement) MixTranscript(data []byte) error {
+	if k.derived {
+		return fmt.Errorf("%w: MixTranscript after DeriveKeys", ErrHandshakeOrder)
+	}
+
+	k.mix(data)
+
+	return nil
+}
+
+// ECDH does the Diffie-Hellman operations, one of them on the TKey,
+// which requires touch if requireTouch was set.
+func (k *KeyAgreement) ECDH() error {
+	switch {
+	case k.agreed:
+		return fmt.Errorf("%w: ECDH already done", ErrHandshakeOrder)
+	case !k.sent:
+		return fmt.Errorf("%w: ECDH before SendEphemeral", ErrHandshakeOrder)
+	case !k.received:
+		return fmt.Errorf("%w: ECDH before ReceivePeerKeys", ErrHandshakeOrder)
+	}
+
+	ee, err := curve25519.X25519(k.ephemeral, k.peerEph)
+	if err != nil {
+		return fmt.Errorf("X25519: %w", err)
+	}
+	defer wipe(ee)
+
+	// Our ephemeral with the peer's static key
+	ephStatic, err := curve25519.X25519(k.ephemeral, k.peerStatic)
+	if err != nil {
+		return fmt.Errorf("X25519: %w", err)
+	}
+	defer wipe(ephStatic)
+
+	// Our static key, on the TKey, with the peer's ephemeral
+	var peerEph [32]byte
+	copy(peerEph[:], k.peerEph)
+	staticEph, err := k.x.DoECDH(k.domain, k.userSecret, k.requireTouch, peerEph)
+	if err != nil {
+		return err
+	}
+	defer wipe(staticEph)
+
+	wipe(k.ephemeral)
+	k.agreed = true
+
+	if k.role == Initiator {
+		k.mix(k.staticPub, k.ephemeralPub, k.peerStatic, k.peerEph)
+		k.secret = concat(ee, ephStatic, staticEph)
+	} else {
+		k.mix(k.peerStatic, k.peerEph, k.staticPub, k.ephemeralPub)
+		k.secret = concat(ee, staticEph, ephStatic)
+	}
+
+	return nil
+}
+
+// DeriveKeys derives the final keys, one for sending to the peer and
+// one for receiving from it. The peer's sendKey is our recvKey, and
+// the other way around.
+func (k *KeyAgreement) DeriveKeys() (sendKey []byte, recvKey []byte, err error) {
+	switch {
+	case k.derived:
+		return nil, nil, fmt.Errorf("%w: DeriveKeys already done", ErrHandshakeOrder)
+	case !k.agreed:
+		return nil, nil, fmt.Errorf("%w: DeriveKeys before ECDH", ErrHandshakeOrder)
+	}
+
+	keys := hkdfSHA256(k.secret, k.transcript, KeyAgreementInfo, 2*32)
+	wipe(k.secret)
+	k.derived = true
+
+	if k.role == Initiator {
+		return keys[:32], keys[32:], nil
+	}
+	return keys[32:], keys[:32], nil
+}
+
+// TranscriptHash returns the current transcript hash. After ECDH both
+// parties have the same hash, which can be used for channel binding.
+func (k *KeyAgreement) TranscriptHash() []byte {
+	return append([]byte(nil), k.transcript...)
+}
+
+func (k *KeyAgreement) mix(data ...[]byte) {
+	for _, d := range data {
+		h := sha256.New()
+		h.Write(k.transcript)
+		h.Write(d)
+		k.transcript = h.Sum(nil)
+	}
+}
+
+func concat(bs ...[]byte) []byte {
+	var out []byte
+	for _, b := range bs {
+		out = append(out, b...)
+	}
+	return out
+}
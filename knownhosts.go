@@ -0,0 +1,40 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+)
+
+// KnownHostsKeyType is the key type name used by KnownHostsLine. It is
+// not a registered SSH key type, and no SSH implementation knows it.
+const KnownHostsKeyType = "x25519-tkey"
+
+// KnownHostsLine formats the X25519 public key pub as a line for an
+// OpenSSH known_hosts file, for experimental SSH transports that use
+// X25519 host keys. The line is hostname, KnownHostsKeyType, and the
+// base64 (standard, padded) encoded key blob, separated by a space.
+// hostname is used as is, so it can be a comma separated list of
+// names, or "[host]:port" for a non-standard port, as known_hosts
+// allows; hashed host names are not made.
+//
+// The key blob follows the SSH wire format of RFC 4253 section 6.6: a
+// string with KnownHostsKeyType followed by a string with the 32 byte
+// key, each string being a big-endian uint32 length followed by the
+// bytes.
+//
+// This is not standard SSH: X25519 is for key agreement, not
+// signatures, so it can not be a host key in the SSH protocol, and
+// OpenSSH skips such lines. The format only gives tools built around
+// TKey host identities a consistent way to store them.
+func KnownHostsLine(hostname string, pub []byte) string {
+	var blob []byte
+	blob = binary.BigEndian.AppendUint32(blob, uint32(len(KnownHostsKeyType)))
+	blob = append(blob, KnownHostsKeyType...)
+	blob = binary.BigEndian.AppendUint32(blob, uint32(len(pub)))
+	blob = append(blob, pub...)
+
+	return hostname + " " + KnownHostsKeyType + " " + base64.StdEncoding.EncodeToString(blob)
+}
@@ -0,0 +1,44 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"golang.org/x/crypto/blake2b"
+)
+
+// KxKeys derives session keys from shared, the X25519 shared secret of
+// a client and a server, exactly as libsodium's crypto_kx does, so a
+// TKey can talk to peers using crypto_kx_client_session_keys or
+// crypto_kx_server_session_keys. rx is the key for receiving, tx for
+// sending; the client's rx is the server's tx, and the other way
+// around.
+//
+// The keys are the 64 byte BLAKE2b-512 hash (unkeyed) of shared,
+// clientPub, and serverPub. The client's rx is the first 32 bytes and
+// its tx the last 32; the server's tx is the first 32 bytes and its rx
+// the last 32. The TKey side gets shared from DoECDH with the peer's
+// public key, which rejects an all-zero shared secret, as crypto_kx
+// does.
+func KxKeys(shared []byte, clientPub, serverPub [32]byte, isClient bool) (rx, tx [32]byte) {
+	h, err := blake2b.New512(nil)
+	if err != nil {
+		// Only happens for a key longer than 64 bytes
+		panic(err)
+	}
+	h.Write(shared)
+	h.Write(clientPub[:])
+	h.Write(serverPub[:])
+	keys := h.Sum(nil)
+	defer wipe(keys)
+
+	if isClient {
+		copy(rx[:], keys[:32])
+		copy(tx[:], keys[32:])
+	} else {
+		copy(tx[:], keys[:32])
+		copy(rx[:], keys[32:])
+	}
+
+	return rx, tx
+}
@@ -0,0 +1,69 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// Keys generated using libsodium's crypto_kx_keypair, and session keys
+// from crypto_kx_client_session_keys and
+// crypto_kx_server_session_keys.
+const (
+	kxClientSecret = "da82bbb3d9aaca55fc30689abb852e86b6b263b3a5d063f1b20ed67242be05d4"
+	kxClientPub    = "b85c8627bf1d4531f1a014f6b4bd908468635023ac701085e8cfb81d99297d0c"
+	kxServerPub    = "e7a15a80c172b6d248c1b0afee49359a747434dbb490ff2fb4e322f4f76b1d39"
+	kxClientRx     = "089e61e1203f0127cd7fdf05fbd2e769096bced5d9552a3acda989bab7e1da34"
+	kxClientTx     = "d913aa7cfc0f1c649b700317409843e141b1a604a4d2ebcfbc627f5daecf1c16"
+	kxServerRx     = "d913aa7cfc0f1c649b700317409843e141b1a604a4d2ebcfbc627f5daecf1c16"
+	kxServerTx     = "089e61e1203f0127cd7fdf05fbd2e769096bced5d9552a3acda989bab7e1da34"
+)
+
+func mustKey(t *testing.T, s string) [32]byte {
+	t.Helper()
+
+	var key [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(key) {
+		t.Fatalf("bad test key %q", s)
+	}
+	copy(key[:], b)
+
+	return key
+}
+
+func TestKxKeysLibsodium(t *testing.T) {
+	clientSecret := mustKey(t, kxClientSecret)
+	clientPub := mustKey(t, kxClientPub)
+	serverPub := mustKey(t, kxServerPub)
+
+	shared, err := curve25519.X25519(clientSecret[:], serverPub[:])
+	if err != nil {
+		t.Fatalf("X25519: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		isClient bool
+		rx, tx   string
+	}{
+		{"client", true, kxClientRx, kxClientTx},
+		{"server", false, kxServerRx, kxServerTx},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rx, tx := KxKeys(shared, clientPub, serverPub, tt.isClient)
+			if want := mustKey(t, tt.rx); rx != want {
+				t.Errorf("rx: got %x, want %x", rx, want)
+			}
+			if want := mustKey(t, tt.tx); tx != want {
+				t.Errorf("tx: got %x, want %x", tx, want)
+			}
+		})
+	}
+}
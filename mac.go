@@ -0,0 +1,47 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/subtle"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// MessageMACInfo is the HKDF info label used for deriving the key of
+// MACMessage.
+const MessageMACInfo = "tkeyx25519 message mac"
+
+// MessageMACSize is the size of a MAC from MACMessage.
+const MessageMACSize = blake2s.Size
+
+// MACMessage returns a MAC of message, using a key derived from
+// shared. The key is derived using HKDF-SHA256 with shared as input
+// keying material, no salt, and MessageMACInfo as info, so the raw
+// shared secret is never used as a key directly. The MAC is keyed
+// blake2s-256 (RFC 7693) of message, with that 32 byte key, and is
+// MessageMACSize bytes.
+//
+// The MAC only shows that message came from someone with shared; it
+// does not stop a message from being replayed, so a message counter
+// or similar should be part of message.
+func MACMessage(shared []byte, message []byte) []byte {
+	key := hkdfSHA256(shared, nil, MessageMACInfo, blake2s.Size)
+	defer wipe(key)
+
+	h, err := blake2s.New256(key)
+	if err != nil {
+		// Only happens if the key is longer than 32 bytes
+		panic(err)
+	}
+	h.Write(message)
+
+	return h.Sum(nil)
+}
+
+// VerifyMAC reports whether mac is the MACMessage of message under
+// shared. The comparison is done in constant time.
+func VerifyMAC(shared []byte, message []byte, mac []byte) bool {
+	return subtle.ConstantTimeCompare(mac, MACMessage(shared, message)) == 1
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"fmt"
+)
+
+// The name and output length of the Noise Protocol Framework DH
+// function that the TKey's X25519 keys are for.
+const (
+	NoiseDHName = "25519"
+	NoiseDHLen  = 32
+)
+
+// NoiseStaticKey gets the public key for domainString, userSecret,
+// and requireTouch (see GetPubKey), for use as the local static
+// public key of a Noise handshake. It is exactly what GetPubKey
+// returns: the 32 byte u-coordinate, little-endian as specified for
+// X25519 by RFC 7748, which is also the encoding the Noise "25519" DH
+// function uses. No conversion is needed.
+func (x X25519) NoiseStaticKey(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, opts ...Option) ([]byte, error) {
+	pub, err := x.GetPubKey(domainString, userSecret, requireTouch, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pub) != NoiseDHLen {
+		return nil, fmt.Errorf("%w: %d bytes", ErrPubKeyLength, len(pub))
+	}
+
+	return pub, nil
+}
+
+// NoiseDH does the Noise "25519" DH function with the local static
+// key kept on the TKey. Noise libraries take the static key pair as a
+// private and public key, and call DH(privateKey, publicKey); when
+// the private key is the static one, call NoiseDH.DH with publicKey,
+// and ignore privateKey. For example, with github.com/flynn/noise,
+// wrap noise.DH25519 so its DH method calls NoiseDH.DH for the static
+// key, and set the public half of StaticKeypair to NoiseStaticKey.
+//
+// Ephemeral keys, and DH with them, are done in software by the Noise
+// library as usual. Only the es/se DH with our static key is done on
+// the TKey, and may require touch.
+type NoiseDH struct {
+	x            X25519
+	domain       string
+	userSecret   [UserSecretSize]byte
+	requireTouch bool
+}
+
+// NewNoiseDH returns a NoiseDH for the key on the TKey for
+// domainString, userSecret, and requireTouch (see GetPubKey).
+func (x X25519) NewNoiseDH(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, opts ...Option) *NoiseDH {
+	return &NoiseDH{
+		x:            x.with(opts),
+		domain:       domainString,
+		userSecret:   userSecret,
+		requireTouch: requireTouch,
+	}
+}
+
+// DH does DoECDH with pub, the peer's public key, returning the
+// NoiseDHLen byte shared secret. As the Noise specification allows,
+// it fails for a public key of small order (ErrSmallOrderPoint)
+// instead of returning the all-zero output.
+func (d *NoiseDH) DH(pub []byte) ([]byte, error) {
+	if len(pub) != NoiseDHLen {
+		return nil, fmt.Errorf("%w: %d bytes", ErrPubKeyLength, len(pub))
+	}
+
+	var theirPubKey [32]byte
+	copy(theirPubKey[:], pub)
+
+	return d.x.DoECDH(d.domain, d.userSecret, d.requireTouch, theirPubKey)
+}
+
+// DHLen returns NoiseDHLen.
+func (d *NoiseDH) DHLen() int {
+	return NoiseDHLen
+}
+
+// DHName returns NoiseDHName.
+func (d *NoiseDH) DHName() string {
+	return NoiseDHName
+}
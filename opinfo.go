@@ -0,0 +1,63 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"time"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// OperationInfo describes the last operation done on the TKey, see
+// LastOperationInfo.
+type OperationInfo struct {
+	// Command is the name of the last command sent to the device
+	// app, or "" if none was.
+	Command string
+	// Touch tells whether the operation waited for touch.
+	Touch bool
+	// AppName and AppVersion are the name (see GetAppNameVersion)
+	// and version of the device app, if it has been asked for them
+	// on this connection, or "" and 0.
+	AppName    string
+	AppVersion uint32
+	// RoundTrip is the time from sending Command until its
+	// response was read.
+	RoundTrip time.Duration
+}
+
+// LastOperationInfo returns what the last operation on the TKey did,
+// such as GetPubKey or DoECDH, for diagnostics. It is reset when each
+// operation starts, and shared by all copies of x. Nothing is
+// recorded about the keys or secrets involved.
+func (x X25519) LastOperationInfo() OperationInfo {
+	x.st.mu.Lock()
+	defer x.st.mu.Unlock()
+
+	info := x.st.lastOp
+	if x.st.nameVer != nil {
+		info.AppName = appName(x.st.nameVer)
+		info.AppVersion = x.st.nameVer.Version
+	}
+
+	return info
+}
+
+// noteOperation updates the info returned by LastOperationInfo using
+// f.
+func (x X25519) noteOperation(f func(info *OperationInfo)) {
+	x.st.mu.Lock()
+	defer x.st.mu.Unlock()
+
+	f(&x.st.lastOp)
+}
+
+// noteNameVersion remembers the device app's name and version, for
+// LastOperationInfo.
+func (x X25519) noteNameVersion(nameVer *tkeyclient.NameVersion) {
+	x.st.mu.Lock()
+	defer x.st.mu.Unlock()
+
+	x.st.nameVer = nameVer
+}
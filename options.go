@@ -0,0 +1,286 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// Option configures an X25519. Options can be passed to New, and
+// then apply to all commands. Most options can also be passed to the
+// individual methods, and then apply to that call only, on top of
+// the options given to New. Options that change how the connection
+// is set up, like Record, only have effect when passed to New.
+type Option func(*options)
+
+type options struct {
+	record               io.Writer
+	strictECDH           bool
+	ctx                  context.Context
+	timeouts             Timeouts
+	logger               Logger
+	frameTrace           bool
+	manualTimeouts       bool
+	progress             func(step string, pct int)
+	requiredApp          *requiredApp
+	transcript           bool
+	touchRetry           *touchRetry
+	maxQueue             int
+	flushBetweenCommands bool
+	events               chan<- Event
+	endpoint             *tkeyclient.Endpoint
+	touchFallback        func() bool
+	watchdog             time.Duration
+	reconnect            func() (Transport, error)
+	errorContext         string
+	rand                 io.Reader
+	rawErrorResponses    bool
+	deviceBinding        bool
+	tracer               Tracer
+	frameResync          int
+	expectedPubKey       *expectedPubKey
+	defaultUserSecret    *[UserSecretSize]byte
+	userSecretChecksum   bool
+	noZeroPeerKeyCheck   bool
+	maxDomains           int
+	chunked              bool
+	touchSpecPrompt      func(spec IdentitySpec) bool
+}
+
+type touchRetry struct {
+	max         int
+	shouldRetry func(attempt int) bool
+}
+
+type requiredApp struct {
+	name    string
+	version int
+}
+
+// Logger is used to log what X25519 does, see WithLogger. It is
+// satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// with returns a copy of x, with opts applied on top of its options.
+func (x X25519) with(opts []Option) X25519 {
+	for _, opt := range opts {
+		opt(&x.opts)
+	}
+	return x
+}
+
+func (o options) context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+// random returns the source of randomness set by WithRand, or
+// crypto/rand.
+func (o options) random() io.Reader {
+	if o.rand == nil {
+		return rand.Reader
+	}
+	return o.rand
+}
+
+func (x X25519) logf(format string, v ...any) {
+	if x.opts.logger != nil {
+		x.opts.logger.Printf(format, v...)
+	}
+}
+
+// errorContext wraps err from sending cmd with the context set by
+// WithErrorContext, if any.
+func (x X25519) errorContext(cmd tkeyclient.Cmd, err error) error {
+	if x.opts.errorContext == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %s: %w", x.opts.errorContext, cmd, err)
+}
+
+// durationToSeconds rounds d up to whole seconds, as needed by
+// tkeyclient's SetReadTimeout.
+func durationToSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// Record makes the X25519 write a capture of all frames sent to and
+// received from the TKey to w, for later use with ReplayFrom. Note
+// that the frames include the userSecret and shared secrets of every
+// command, so only record sessions using test secrets.
+func Record(w io.Writer) Option {
+	return func(o *options) {
+		o.record = w
+	}
+}
+
+// WithStrictECDH makes DoECDH, in addition to rejecting an all-zero
+// shared secret, reject a shared secret that is any of the other
+// small order points on Curve25519, returning ErrLowOrderResult. A
+// correctly working device app never outputs such a point, so this
+// guards against a faulty device app or a corrupted response.
+func WithStrictECDH() Option {
+	return func(o *options) {
+		o.strictECDH = true
+	}
+}
+
+// WithContext makes commands check ctx before talking to the TKey,
+// returning ctx.Err() if it is done. A command still waiting for
+// another one to finish (see WithMaxQueue) leaves the queue as soon
+// as ctx is done, without ever reaching the TKey. Note that a command
+// already waiting for a response from the TKey is not interrupted.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// WithTouchTimeout makes DoECDH with requireTouch give up waiting for
+// a response after d (rounded up to whole seconds). The default is to
+// wait for as long as the device app does.
+func WithTouchTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeouts.Touch = d
+	}
+}
+
+// WithTouchRetry makes DoECDH with requireTouch send the command
+// again when the device app reports that the user did not touch the
+// TKey in time, up to max more times. Before each retry
+// shouldRetry is called with the number of the attempt that timed
+// out (starting at 1), and the command is only sent again if it
+// returns true; this is where a user interface can ask whether to
+// keep waiting. A nil shouldRetry always retries. The default is to
+// not retry.
+//
+// Only touch timeouts reported by the device app are retried. I/O
+// errors, including the read timeout of WithTouchTimeout (after which
+// the device app may still be waiting for a touch), are returned
+// right away.
+func WithTouchRetry(max int, shouldRetry func(attempt int) bool) Option {
+	return func(o *options) {
+		o.touchRetry = &touchRetry{max: max, shouldRetry: shouldRetry}
+	}
+}
+
+// WithTouchFallback makes DoECDH with requireTouch, when the device
+// app reports that the user did not touch the TKey in time (after any
+// retries, see WithTouchRetry), call consent, and if it returns true,
+// do ECDH again with requireTouch false. The default is no fallback.
+//
+// Beware: requireTouch is part of the key derivation (see GetPubKey),
+// so the fallback uses a different private key, whose public key is
+// the one from GetPubKey with requireTouch false. The shared secret
+// will not match what a peer computed using the touch key's public
+// key. This is only useful if the peer knows both public keys, and
+// accepts the no-touch one. consent must make this clear to the user
+// before returning true.
+//
+// The fallback is part of the same DoECDH: it is not traced as a
+// DoECDH of its own, nor counted again by WithMaxDomains. With
+// WithExpectedPubKey for the touch key, that key is checked before
+// touch is asked for, and the no-touch key used by the fallback is
+// not checked.
+func WithTouchFallback(consent func() bool) Option {
+	return func(o *options) {
+		o.touchFallback = consent
+	}
+}
+
+// WithErrorContext makes errors from talking to the TKey start with
+// device, for example its serial port path, and the name of the
+// command, like "/dev/ttyACM0: cmdDoECDH: ReadFrame: ...". This
+// tells which device failed when several are in use. The errors
+// still match the same errors with errors.Is and errors.As.
+func WithErrorContext(device string) Option {
+	return func(o *options) {
+		o.errorContext = device
+	}
+}
+
+// WithRand makes functions that generate ephemeral keys in software,
+// like SetupHPKESender, NewStreamEncryptor, and KeyAgreement's
+// SendEphemeral, read them from r instead of crypto/rand. This is for
+// tests with fixed vectors only: with a predictable r, anyone can
+// compute the shared secrets.
+func WithRand(r io.Reader) Option {
+	return func(o *options) {
+		o.rand = r
+	}
+}
+
+// WithRawErrorResponses makes a *ResponseStatusNotOKError carry the
+// whole response frame, see RawResponse, and has it logged in hex
+// using the logger of WithLogger. This is for debugging device apps.
+// A not-OK response normally holds nothing but the status, but it is
+// logged as received, so only use this with test secrets.
+func WithRawErrorResponses() Option {
+	return func(o *options) {
+		o.rawErrorResponses = true
+	}
+}
+
+// WithLogger makes X25519 log the commands it sends and the
+// responses it receives to l. No secrets are logged.
+func WithLogger(l Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithFrameTrace makes X25519 additionally log, using the logger set
+// by WithLogger, the framing header byte and command code of each
+// frame sent and received, for matching up with a capture of the USB
+// traffic. The payload, which holds the userSecret and shared
+// secrets, is never logged.
+func WithFrameTrace() Option {
+	return func(o *options) {
+		o.frameTrace = true
+	}
+}
+
+// WithManualTimeouts stops X25519 from setting and resetting the read
+// timeout of the connection, which it otherwise does for commands
+// like GetAppNameVersion (to not hang on a TKey not running the
+// X25519 device app) and for DoECDH when using WithTouchTimeout. The
+// caller is then responsible for the timeout, using SetReadTimeout.
+func WithManualTimeouts() Option {
+	return func(o *options) {
+		o.manualTimeouts = true
+	}
+}
+
+// WithProgress makes multi-step operations like Provision call f as
+// they go, with a description of the step being started and an
+// estimate of how far along (in percent) the operation is. It is
+// purely informational, for showing progress in a user interface.
+func WithProgress(f func(step string, pct int)) Option {
+	return func(o *options) {
+		o.progress = f
+	}
+}
+
+// WithRequiredAppVersion makes the first command check that the
+// device app has exactly the name and version given, failing with an
+// *AppVersionMismatchError (matching ErrAppVersionMismatch) if not.
+// The name is the app's Name0 and Name1 concatenated, with trailing
+// spaces removed (see GetAppNameVersion). This is stricter than a
+// minimum version, and protects against behaviour changing when the
+// device app is updated. A negative version never matches.
+func WithRequiredAppVersion(name string, version int) Option {
+	return func(o *options) {
+		o.requiredApp = &requiredApp{name: name, version: version}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// userSecretCommitmentLabel is prepended to the userSecret when
+// hashing it in DerivationParams.
+const userSecretCommitmentLabel = "tkeyx25519 user secret commitment"
+
+// DerivationParameters are the inputs to key derivation, as sent to
+// the device app, in a form safe to log. See DerivationParams.
+type DerivationParameters struct {
+	// Domain is the 32 byte domain sent to the device app, in hex.
+	Domain string
+	// TouchByte is the touch byte sent to the device app.
+	TouchByte byte
+	// UserSecretCommitment is a hash of the userSecret, in hex.
+	UserSecretCommitment string
+}
+
+func (p DerivationParameters) String() string {
+	return fmt.Sprintf("domain %s, touch byte %d, user secret commitment %s",
+		p.Domain, p.TouchByte, p.UserSecretCommitment)
+}
+
+// DerivationParams returns the parameters that GetPubKey and DoECDH
+// send to the device app for domainString, userSecret, and
+// requireTouch, for logging and comparing across machines. The same
+// parameters on the same TKey and device app give the same key.
+//
+// The userSecret is not included, only a commitment to it: the
+// blake2s-256 hash of userSecretCommitmentLabel ("tkeyx25519 user
+// secret commitment") followed by the userSecret. Equal commitments
+// mean equal userSecrets, but the userSecret can not be recovered
+// from the commitment, provided it is random as it should be.
+func DerivationParams(domainString string, userSecret [UserSecretSize]byte, requireTouch bool) DerivationParameters {
+	domain := normalizeDomain(domainString)
+	data := append([]byte(userSecretCommitmentLabel), userSecret[:]...)
+	commitment := blake2s.Sum256(data)
+	wipe(data)
+
+	return DerivationParameters{
+		Domain:               hex.EncodeToString(domain[:]),
+		TouchByte:            touchByte(requireTouch),
+		UserSecretCommitment: hex.EncodeToString(commitment[:]),
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters used by UserSecretFromPassphrase. These are
+// part of the derivation; changing any of them changes every
+// userSecret (and thereby every key) derived from a passphrase.
+const (
+	PassphraseArgon2Time    = 4
+	PassphraseArgon2Memory  = 256 * 1024 // KiB, that is 256 MiB
+	PassphraseArgon2Threads = 4
+	PassphraseMinSaltSize   = 16
+)
+
+// UserSecretFromPassphrase derives a userSecret from a memorized
+// passphrase using Argon2id with the Passphrase* parameters above.
+// The salt must be at least PassphraseMinSaltSize bytes, and must be
+// stored (it need not be secret) to derive the same userSecret again.
+//
+// The userSecret is only as strong as the passphrase. A public key
+// alone does not allow guessing it, since the device app also uses
+// the TKey's CDI when deriving keys. But anyone holding the TKey and
+// the salt can guess online, comparing the public keys derived with
+// a known one, and anyone holding the salt and something computed on
+// the host from the userSecret alone (such as a commitment or
+// checksum of it) can guess offline. Argon2id makes each guess
+// expensive, but a short or guessable passphrase can still be brute
+// forced. Use a long, randomly generated passphrase (such as a
+// diceware phrase of 6 or more words) if you use this at all; random
+// bytes from crypto/rand remain the recommended userSecret.
+func UserSecretFromPassphrase(passphrase string, salt []byte) ([UserSecretSize]byte, error) {
+	var userSecret [UserSecretSize]byte
+
+	if passphrase == "" {
+		return userSecret, errors.New("empty passphrase")
+	}
+	if len(salt) < PassphraseMinSaltSize {
+		return userSecret, fmt.Errorf("salt too short (%d < %d)", len(salt), PassphraseMinSaltSize)
+	}
+
+	key := argon2.IDKey([]byte(passphrase), salt,
+		PassphraseArgon2Time, PassphraseArgon2Memory, PassphraseArgon2Threads,
+		UserSecretSize)
+	copy(userSecret[:], key)
+	wipe(key)
+
+	return userSecret, nil
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// oidX25519 is the algorithm identifier of an X25519 key, from RFC
+// 8410.
+var oidX25519 = asn1.ObjectIdentifier{1, 3, 101, 110}
+
+// pemPublicKeyType is the PEM block type of a PKIX public key.
+const pemPublicKeyType = "PUBLIC KEY"
+
+// subjectPublicKeyInfo is the PKIX SubjectPublicKeyInfo structure of
+// RFC 5280, section 4.1.
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// ParsePubKeyPEM parses an X25519 public key from the first PEM block
+// in data, which must be a "PUBLIC KEY" block holding a PKIX
+// SubjectPublicKeyInfo, as written by OpenSSL ("openssl pkey -pubout")
+// and others. The key must have the X25519 algorithm identifier of
+// RFC 8410 (1.3.101.110), and no parameters.
+func ParsePubKeyPEM(data []byte) ([32]byte, error) {
+	var pub [32]byte
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return pub, errors.New("no PEM block found")
+	}
+	if block.Type != pemPublicKeyType {
+		return pub, fmt.Errorf("PEM block type %q, expected %q", block.Type, pemPublicKeyType)
+	}
+
+	var spki subjectPublicKeyInfo
+	rest, err := asn1.Unmarshal(block.Bytes, &spki)
+	if err != nil {
+		return pub, fmt.Errorf("Unmarshal: %w", err)
+	}
+	if len(rest) != 0 {
+		return pub, errors.New("trailing data after public key")
+	}
+
+	if !spki.Algorithm.Algorithm.Equal(oidX25519) {
+		return pub, fmt.Errorf("unsupported key type %v, expected X25519 (%v)", spki.Algorithm.Algorithm, oidX25519)
+	}
+	if len(spki.Algorithm.Parameters.FullBytes) != 0 {
+		return pub, errors.New("X25519 algorithm with parameters")
+	}
+
+	key := spki.PublicKey.RightAlign()
+	if spki.PublicKey.BitLength != 8*len(pub) || len(key) != len(pub) {
+		return pub, fmt.Errorf("%w: %d bits", ErrPubKeyLength, spki.PublicKey.BitLength)
+	}
+	copy(pub[:], key)
+
+	return pub, nil
+}
+
+// DoECDHPEM does DoECDH with the peer's public key parsed from
+// peerPEM, see ParsePubKeyPEM.
+func (x X25519) DoECDHPEM(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, peerPEM []byte, opts ...Option) ([]byte, error) {
+	theirPubKey, err := ParsePubKeyPEM(peerPEM)
+	if err != nil {
+		return nil, fmt.Errorf("peer public key: %w", err)
+	}
+
+	return x.DoECDH(domainString, userSecret, requireTouch, theirPubKey, opts...)
+}
+
+// PubKeyToPEM encodes the X25519 public key pub as a "PUBLIC KEY" PEM
+// block holding a PKIX SubjectPublicKeyInfo with the X25519 algorithm
+// identifier of RFC 8410, as OpenSSL and other standard tools expect.
+// ParsePubKeyPEM gives back pub.
+func PubKeyToPEM(pub []byte) ([]byte, error) {
+	if len(pub) != 32 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrPubKeyLength, len(pub))
+	}
+
+	der, err := asn1.Marshal(subjectPublicKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidX25519},
+		PublicKey: asn1.BitString{Bytes: pub, BitLength: 8 * len(pub)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Marshal: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: pemPublicKeyType, Bytes: der}), nil
+}
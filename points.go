@@ -0,0 +1,76 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/subtle"
+	"fmt"
+)
+
+// lowOrderPoints are the canonical (little-endian, reduced mod p)
+// u-coordinates of the points of small order on Curve25519: 0, 1,
+// the two points of order 8, and p-1.
+var lowOrderPoints = [][32]byte{
+	{},
+	{0x01},
+	{
+		0xe0, 0xeb, 0x7a, 0x7c, 0x3b, 0x41, 0xb8, 0xae, 0x16, 0x56, 0xe3, 0xfa, 0xf1, 0x9f, 0xc4, 0x6a,
+		0xda, 0x09, 0x8d, 0xeb, 0x9c, 0x32, 0xb1, 0xfd, 0x86, 0x62, 0x05, 0x16, 0x5f, 0x49, 0xb8, 0x00,
+	},
+	{
+		0x5f, 0x9c, 0x95, 0xbc, 0xa3, 0x50, 0x8c, 0x24, 0xb1, 0xd0, 0xb1, 0x55, 0x9c, 0x83, 0xef, 0x5b,
+		0x04, 0x44, 0x5c, 0xc4, 0x58, 0x1c, 0x8e, 0x86, 0xd8, 0x22, 0x4e, 0xdd, 0xd0, 0x9f, 0x11, 0x57,
+	},
+	{
+		0xec, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f,
+	},
+}
+
+// isLowOrderPoint reports, in constant time, whether u is one of
+// lowOrderPoints.
+func isLowOrderPoint(u []byte) bool {
+	var found int
+	for i := range lowOrderPoints {
+		found |= subtle.ConstantTimeCompare(u, lowOrderPoints[i][:])
+	}
+	return found == 1
+}
+
+// IsValidSharedSecret reports whether secret can be used as an X25519
+// shared secret. It rejects a secret that is not 32 bytes long, and
+// one that is any of lowOrderPoints: the all-zero secret that results
+// from ECDH with a public key of small order, and the other small
+// order points, which correctly computed ECDH never results in. This
+// is the check done by DoECDH with WithStrictECDH.
+//
+// The check is done in constant time: the time taken only depends on
+// the length of secret, not on its value.
+func IsValidSharedSecret(secret []byte) bool {
+	return checkSharedSecret(secret, true) == nil
+}
+
+// checkSharedSecret checks secret as described for
+// IsValidSharedSecret, in constant time, returning ErrSmallOrderPoint
+// if it is all zero. The other small order points are only rejected,
+// with ErrLowOrderResult, if strict is true.
+func checkSharedSecret(secret []byte, strict bool) error {
+	if len(secret) != 32 {
+		return fmt.Errorf("wrong shared secret length %d", len(secret))
+	}
+
+	// Both checks are always done, so that the time taken does not
+	// depend on whether secret is all zero
+	zero := isAllZero(secret)
+	lowOrder := isLowOrderPoint(secret)
+
+	switch {
+	case zero:
+		return ErrSmallOrderPoint
+	case strict && lowOrder:
+		return ErrLowOrderResult
+	}
+
+	return nil
+}
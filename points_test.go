@@ -0,0 +1,86 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestSharedSecretChecks(t *testing.T) {
+	var random [32]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure it is neither all zero nor of low order
+	random[0] |= 0x80
+	random[31] &= 0x3f
+
+	tests := []struct {
+		name     string
+		secret   [32]byte
+		zero     bool
+		lowOrder bool
+	}{
+		{"zero", lowOrderPoints[0], true, true},
+		{"one", lowOrderPoints[1], false, true},
+		{"order 8 a", lowOrderPoints[2], false, true},
+		{"order 8 b", lowOrderPoints[3], false, true},
+		{"p-1", lowOrderPoints[4], false, true},
+		{"random", random, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := tt.secret[:]
+
+			if got := isAllZero(secret); got != tt.zero {
+				t.Errorf("isAllZero: got %v, want %v", got, tt.zero)
+			}
+			if got := IsValidSharedSecret(secret); got != !tt.lowOrder {
+				t.Errorf("IsValidSharedSecret: got %v, want %v", got, !tt.lowOrder)
+			}
+
+			wantErr := error(nil)
+			switch {
+			case tt.zero:
+				wantErr = ErrSmallOrderPoint
+			case tt.lowOrder:
+				wantErr = ErrLowOrderResult
+			}
+			if err := checkSharedSecret(secret, true); !errors.Is(err, wantErr) {
+				t.Errorf("checkSharedSecret strict: got %v, want %v", err, wantErr)
+			}
+			if !tt.zero {
+				wantErr = nil
+			}
+			if err := checkSharedSecret(secret, false); !errors.Is(err, wantErr) {
+				t.Errorf("checkSharedSecret: got %v, want %v", err, wantErr)
+			}
+
+			// Changing any single byte, wherever it is, must be
+			// seen, so every byte is looked at
+			for i := range secret {
+				changed := tt.secret
+				changed[i] ^= 0x40
+
+				if isAllZero(changed[:]) {
+					t.Errorf("isAllZero with byte %d changed: got true", i)
+				}
+				if tt.lowOrder && !IsValidSharedSecret(changed[:]) {
+					t.Errorf("IsValidSharedSecret with byte %d changed: got false", i)
+				}
+			}
+		})
+	}
+}
+
+func TestSharedSecretLength(t *testing.T) {
+	for _, n := range []int{0, 31, 33} {
+		if IsValidSharedSecret(make([]byte, n)) {
+			t.Errorf("IsValidSharedSecret of %d bytes: got true", n)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// possessionProofLabel is the first part of the message authenticated
+// in a proof from ProveKeyPossession.
+const possessionProofLabel = "tkeyx25519 key possession"
+
+// Proof of possession lets a verifier, who knows the public key of an
+// identity on a TKey, check that the prover has that TKey (and the
+// userSecret), without anything secret being revealed:
+//
+//  1. The verifier calls NewPossessionChallenge, keeps the private
+//     key, and sends the challenge public key to the prover.
+//  2. The prover calls ProveKeyPossession with the challenge, which
+//     does ECDH on the TKey, and sends the proof back.
+//  3. The verifier calls VerifyKeyPossession with the identity's
+//     public key, the challenge private key, and the proof.
+//
+// The proof is HMAC-SHA256 with the ECDH shared secret as key, over
+// possessionProofLabel ("tkeyx25519 key possession") followed by the
+// challenge public key. Only someone who can do ECDH with the
+// identity's private key, or with the challenge private key, can
+// compute it. A fresh challenge must be used every time, or an old
+// proof could be replayed.
+
+// NewPossessionChallenge generates a challenge for proof of
+// possession, returning the private key, to be kept by the verifier,
+// and the public key, to be sent to the prover. Of opts, only WithRand
+// applies.
+func NewPossessionChallenge(opts ...Option) (challengePriv []byte, challengePub [32]byte, err error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	priv, pub, err := generateEphemeral(o.random())
+	if err != nil {
+		return nil, challengePub, err
+	}
+	copy(challengePub[:], pub)
+
+	return priv, challengePub, nil
+}
+
+// ProveKeyPossession proves having the key on the TKey for
+// domainString, userSecret, and requireTouch (see GetPubKey), by
+// doing ECDH with challengePub from the verifier (see
+// NewPossessionChallenge), and returning the proof to send back.
+func (x X25519) ProveKeyPossession(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, challengePub [32]byte, opts ...Option) ([]byte, error) {
+	shared, err := x.DoECDH(domainString, userSecret, requireTouch, challengePub, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(shared)
+
+	return possessionProof(shared, challengePub[:]), nil
+}
+
+// VerifyKeyPossession checks proof from ProveKeyPossession, against
+// the public key of the identity, pub, and challengePriv from
+// NewPossessionChallenge. It returns false if the proof is wrong, and
+// an error if pub can not be used.
+func VerifyKeyPossession(pub []byte, challengePriv []byte, proof []byte) (bool, error) {
+	challengePub, err := curve25519.X25519(challengePriv, curve25519.Basepoint)
+	if err != nil {
+		return false, fmt.Errorf("X25519: %w", err)
+	}
+
+	shared, err := curve25519.X25519(challengePriv, pub)
+	if err != nil {
+		return false, fmt.Errorf("X25519: %w", err)
+	}
+	defer wipe(shared)
+
+	return hmac.Equal(proof, possessionProof(shared, challengePub)), nil
+}
+
+func possessionProof(shared []byte, challengePub []byte) []byte {
+	mac := hmac.New(sha256.New, shared)
+	mac.Write([]byte(possessionProofLabel))
+	mac.Write(challengePub)
+
+	return mac.Sum(nil)
+}
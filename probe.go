@@ -0,0 +1,87 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// probeDomain is the domain of the key used by ProbeCommands. The
+// userSecret used is all zeroes.
+const probeDomain = "tkeyx25519 probe"
+
+// probe is a command tried by ProbeCommands, with a harmless payload.
+type probe struct {
+	cmd  appCmd
+	rsp  appCmd
+	data func() bytes.Buffer
+}
+
+var probes = []probe{
+	{cmdGetNameVersion, rspGetNameVersion, func() bytes.Buffer {
+		return bytes.Buffer{}
+	}},
+	{cmdGetCapabilities, rspGetCapabilities, func() bytes.Buffer {
+		return bytes.Buffer{}
+	}},
+	{cmdGetPubKey, rspGetPubKey, func() bytes.Buffer {
+		return keyParameters(probeDomain, [UserSecretSize]byte{}, touchByte(false))
+	}},
+	// Without touch, and with the base point as public key
+	{cmdDoECDH, rspDoECDH, func() bytes.Buffer {
+		data := keyParameters(probeDomain, [UserSecretSize]byte{}, touchByte(false))
+		data.Write([]byte{9})
+		data.Write(make([]byte, 31))
+		return data
+	}},
+}
+
+// ProbeCommands tries each command known to this package on the
+// device app, and returns a map from command name (like "cmdDoECDH")
+// to whether the device app recognizes it. A command the device app
+// answers, with any status, is recognized; one it answers with a
+// not-OK frame is not.
+//
+// Commands are only tried with harmless payloads: keys are derived
+// for a fixed probe domain and an all-zero userSecret, and DoECDH is
+// done without touch. Commands that would require touch or change
+// the state of the device app are skipped, and left out of the map
+// as unknown; currently there are none.
+//
+// If probing is interrupted, by an I/O error or the context of
+// WithContext, the results so far are returned together with the
+// error.
+func (x X25519) ProbeCommands(opts ...Option) (map[string]bool, error) {
+	x = x.with(opts)
+
+	x, release, err := x.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	supported := make(map[string]bool)
+	for _, p := range probes {
+		err := x.withReadTimeout(PhaseCommand, x.commandTimeout(true), func() error {
+			_, err := x.sendCommand(p.cmd, p.data(), p.rsp)
+			return err
+		})
+
+		var statusErr *ResponseStatusNotOKError
+		switch {
+		case err == nil, errors.As(err, &statusErr):
+			supported[p.cmd.String()] = true
+		case errors.Is(err, tkeyclient.ErrResponseStatusNotOK):
+			supported[p.cmd.String()] = false
+		default:
+			return supported, fmt.Errorf("probing %s: %w", p.cmd, err)
+		}
+	}
+
+	return supported, nil
+}
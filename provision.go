@@ -0,0 +1,284 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tillitis/tkeyclient"
+	"golang.org/x/crypto/curve25519"
+)
+
+// Provision loads the X25519 device app in appBinary onto the TKey
+// connected to in tk, which must be in firmware mode. secretPhrase is
+// the optional USS (see tkeyclient.LoadApp). It then checks that the
+// app responds, and gets the public key for domainString, userSecret,
+// and requireTouch (see GetPubKey). It returns an X25519 for talking
+// to the loaded app, and the public key.
+//
+// Progress through these steps can be followed using WithProgress.
+func Provision(tk *tkeyclient.TillitisKey, appBinary []byte, secretPhrase []byte, domainString string, userSecret [UserSecretSize]byte, requireTouch bool, opts ...Option) (X25519, []byte, error) {
+	x := New(tk, opts...)
+
+	x.progress("Loading app", 0)
+	if err := tk.LoadApp(appBinary, secretPhrase); err != nil {
+		return X25519{}, nil, fmt.Errorf("LoadApp: %w", err)
+	}
+
+	x.progress("Verifying app", 60)
+	if _, err := x.GetAppNameVersion(); err != nil {
+		return X25519{}, nil, err
+	}
+
+	x.progress("Fetching public key", 80)
+	pubKey, err := x.GetPubKey(domainString, userSecret, requireTouch)
+	if err != nil {
+		return X25519{}, nil, err
+	}
+
+	x.progress("Done", 100)
+
+	return x, pubKey, nil
+}
+
+// IdentitySpec describes an identity to set up with
+// ProvisionIdentities.
+type IdentitySpec struct {
+	// Label is a name for the identity, for the user.
+	Label string
+	// Domain is the domainString passed to GetPubKey.
+	Domain string
+	// RequireTouch is the requireTouch passed to GetPubKey.
+	RequireTouch bool
+}
+
+// ProvisionedIdentity is an identity set up by ProvisionIdentities.
+// PubKey is nil if getting the public key failed, or the spec was
+// skipped.
+type ProvisionedIdentity struct {
+	IdentitySpec
+	PubKey []byte
+	// Skipped is true if the spec requires touch, and was skipped
+	// as decided by WithTouchSpecPrompt or WithSkipTouchSpecs.
+	Skipped bool
+}
+
+// Identity returns p as an Identity, without UserSecret, as for
+// ExportIdentities.
+func (p ProvisionedIdentity) Identity() Identity {
+	return Identity{
+		Label:        p.Label,
+		Domain:       p.Domain,
+		RequireTouch: p.RequireTouch,
+		PubKey:       p.PubKey,
+	}
+}
+
+// ProvisionError is returned by ProvisionIdentities if getting the
+// public key failed for some of the specs. Errs has one entry per
+// spec, nil for those that succeeded.
+type ProvisionError struct {
+	Errs []error
+}
+
+func (e *ProvisionError) Error() string {
+	var failed []string
+	for i, err := range e.Errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%d: %v", i, err))
+		}
+	}
+
+	return fmt.Sprintf("provisioning failed for %d of %d identities: %s", len(failed), len(e.Errs), strings.Join(failed, "; "))
+}
+
+// Unwrap returns the first error, so errors.Is and errors.As look at
+// it.
+func (e *ProvisionError) Unwrap() error {
+	for _, err := range e.Errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WithTouchSpecPrompt makes ProvisionIdentities call prompt for each
+// spec with RequireTouch, before getting its public key. If prompt
+// returns false, the spec is skipped. Getting the public key does not
+// itself require touch, but every later DoECDH with the identity
+// will, so this lets the user confirm each such identity. The default
+// is to provision all specs without asking.
+func WithTouchSpecPrompt(prompt func(spec IdentitySpec) bool) Option {
+	return func(o *options) {
+		o.touchSpecPrompt = prompt
+	}
+}
+
+// WithSkipTouchSpecs makes ProvisionIdentities skip all specs with
+// RequireTouch, see WithTouchSpecPrompt.
+func WithSkipTouchSpecs() Option {
+	return WithTouchSpecPrompt(func(IdentitySpec) bool { return false })
+}
+
+// ProvisionIdentities gets the public key for each of specs, using
+// userSecret, for setting up many identities at once. The result has
+// one entry per spec, in order, which can be saved using
+// ExportIdentities (see ProvisionedIdentity.Identity), or handed over
+// in a manifest, see SignProvisionManifest.
+//
+// The TKey is held for the whole batch, so no other command gets in
+// between. Getting a public key never requires touch, also for specs
+// with RequireTouch; touch is only required later, by DoECDH. Specs
+// with RequireTouch can be confirmed one by one, or skipped, using
+// WithTouchSpecPrompt or WithSkipTouchSpecs. If some specs fail, the
+// others are still done, and the error is a *ProvisionError telling
+// which failed. Progress can be followed using WithProgress.
+func (x X25519) ProvisionIdentities(userSecret [UserSecretSize]byte, specs []IdentitySpec, opts ...Option) ([]ProvisionedIdentity, error) {
+	x = x.with(opts)
+
+	x, release, err := x.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	provisioned := make([]ProvisionedIdentity, len(specs))
+	errs := make([]error, len(specs))
+	failed := false
+
+	for i, spec := range specs {
+		x.progress(fmt.Sprintf("Fetching public key for %q", spec.Label), 100*i/len(specs))
+
+		provisioned[i].IdentitySpec = spec
+		if spec.RequireTouch && x.opts.touchSpecPrompt != nil && !x.opts.touchSpecPrompt(spec) {
+			provisioned[i].Skipped = true
+			continue
+		}
+
+		pubKey, err := x.GetPubKey(spec.Domain, userSecret, spec.RequireTouch)
+		if err != nil {
+			errs[i] = err
+			failed = true
+			continue
+		}
+		provisioned[i].PubKey = pubKey
+	}
+
+	x.progress("Done", 100)
+
+	if failed {
+		return provisioned, &ProvisionError{Errs: errs}
+	}
+
+	return provisioned, nil
+}
+
+// provisionManifestLabel is the first part of the message
+// authenticated by the MAC of a ProvisionManifest.
+const provisionManifestLabel = "tkeyx25519 provision manifest"
+
+// ProvisionManifest lists the identities set up by
+// ProvisionIdentities, signed using an identity on the TKey for a
+// verifier, see SignProvisionManifest. It is meant to be marshalled
+// as JSON.
+type ProvisionManifest struct {
+	// Identities are the identities provisioned, without
+	// UserSecret.
+	Identities []Identity `json:"identities"`
+	// SignerPub is the public key of the identity that signed.
+	SignerPub []byte `json:"signer_pub"`
+	// MAC authenticates Identities, SignerPub, and the verifier's
+	// public key.
+	MAC []byte `json:"mac"`
+}
+
+// SignProvisionManifest makes a manifest of the provisioned
+// identities that have a public key, signed by the identity on the
+// TKey for domainString, userSecret, and requireTouch, for the
+// verifier with public key verifierPub.
+//
+// X25519 keys can not make ordinary signatures, so the signature is a
+// MAC that only the verifier can check (see
+// VerifyProvisionManifest): MACMessage, using the shared secret of
+// DoECDH with verifierPub, of provisionManifestLabel ("tkeyx25519
+// provision manifest"), the JSON of Identities, the signer's public
+// key, and verifierPub. It shows the verifier that the manifest came
+// from the holder of the signing identity, whose public key the
+// verifier must know from elsewhere.
+func (x X25519) SignProvisionManifest(provisioned []ProvisionedIdentity, domainString string, userSecret [UserSecretSize]byte, requireTouch bool, verifierPub [32]byte, opts ...Option) (ProvisionManifest, error) {
+	var m ProvisionManifest
+	for _, p := range provisioned {
+		if p.PubKey != nil {
+			m.Identities = append(m.Identities, p.Identity())
+		}
+	}
+
+	signerPub, err := x.GetPubKey(domainString, userSecret, requireTouch, opts...)
+	if err != nil {
+		return ProvisionManifest{}, err
+	}
+	m.SignerPub = signerPub
+
+	shared, err := x.DoECDH(domainString, userSecret, requireTouch, verifierPub, opts...)
+	if err != nil {
+		return ProvisionManifest{}, err
+	}
+	defer wipe(shared)
+
+	msg, err := m.message(verifierPub[:])
+	if err != nil {
+		return ProvisionManifest{}, err
+	}
+	m.MAC = MACMessage(shared, msg)
+
+	return m, nil
+}
+
+// VerifyProvisionManifest checks the MAC of m from
+// SignProvisionManifest, using verifierPriv, the private key of the
+// verifier. It returns false if the MAC is wrong. The caller must
+// also check that m.SignerPub is the public key expected to sign.
+func VerifyProvisionManifest(m ProvisionManifest, verifierPriv []byte) (bool, error) {
+	verifierPub, err := curve25519.X25519(verifierPriv, curve25519.Basepoint)
+	if err != nil {
+		return false, fmt.Errorf("X25519: %w", err)
+	}
+
+	shared, err := curve25519.X25519(verifierPriv, m.SignerPub)
+	if err != nil {
+		return false, fmt.Errorf("X25519: %w", err)
+	}
+	defer wipe(shared)
+
+	msg, err := m.message(verifierPub)
+	if err != nil {
+		return false, err
+	}
+
+	return VerifyMAC(shared, msg, m.MAC), nil
+}
+
+// message returns the message authenticated by the MAC of m.
+func (m ProvisionManifest) message(verifierPub []byte) ([]byte, error) {
+	identities, err := json.Marshal(m.Identities)
+	if err != nil {
+		return nil, fmt.Errorf("Marshal: %w", err)
+	}
+
+	msg := append([]byte(provisionManifestLabel), identities...)
+	msg = append(msg, m.SignerPub...)
+	msg = append(msg, verifierPub...)
+
+	return msg, nil
+}
+
+func (x X25519) progress(step string, pct int) {
+	if x.opts.progress != nil {
+		x.opts.progress(step, pct)
+	}
+}
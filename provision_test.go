@@ -0,0 +1,102 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"testing"
+)
+
+var provisionTestSpecs = []IdentitySpec{
+	{Label: "a", Domain: "a", RequireTouch: false},
+	{Label: "b", Domain: "b", RequireTouch: true},
+	{Label: "c", Domain: "c", RequireTouch: true},
+}
+
+func TestProvisionTouchSpecs(t *testing.T) {
+	userSecret := [UserSecretSize]byte{1}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		skipped []bool
+	}{
+		{"default", nil, []bool{false, false, false}},
+		{"skip", []Option{WithSkipTouchSpecs()}, []bool{false, true, true}},
+		{"prompt", []Option{WithTouchSpecPrompt(func(spec IdentitySpec) bool {
+			return spec.Label == "c"
+		})}, []bool{false, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := newX25519(&fakeDevice{cdi: 1}, nil)
+
+			provisioned, err := x.ProvisionIdentities(userSecret, provisionTestSpecs, tt.opts...)
+			if err != nil {
+				t.Fatalf("ProvisionIdentities: %v", err)
+			}
+
+			for i, p := range provisioned {
+				if p.Skipped != tt.skipped[i] {
+					t.Errorf("spec %d: skipped %v, want %v", i, p.Skipped, tt.skipped[i])
+				}
+				if (p.PubKey == nil) != tt.skipped[i] {
+					t.Errorf("spec %d: public key %x, skipped %v", i, p.PubKey, tt.skipped[i])
+				}
+			}
+		})
+	}
+}
+
+func TestProvisionManifest(t *testing.T) {
+	userSecret := [UserSecretSize]byte{1}
+	x := newX25519(&fakeDevice{cdi: 1}, nil)
+
+	provisioned, err := x.ProvisionIdentities(userSecret, provisionTestSpecs, WithSkipTouchSpecs())
+	if err != nil {
+		t.Fatalf("ProvisionIdentities: %v", err)
+	}
+
+	verifierPriv, verifierPub, err := NewPossessionChallenge()
+	if err != nil {
+		t.Fatalf("NewPossessionChallenge: %v", err)
+	}
+
+	m, err := x.SignProvisionManifest(provisioned, "signer", userSecret, false, verifierPub)
+	if err != nil {
+		t.Fatalf("SignProvisionManifest: %v", err)
+	}
+	if len(m.Identities) != 1 {
+		t.Fatalf("manifest has %d identities, want 1", len(m.Identities))
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(m *ProvisionManifest)
+		ok     bool
+	}{
+		{"intact", func(*ProvisionManifest) {}, true},
+		{"label", func(m *ProvisionManifest) { m.Identities[0].Label = "x" }, false},
+		{"pub key", func(m *ProvisionManifest) { m.Identities[0].PubKey[0] ^= 1 }, false},
+		{"mac", func(m *ProvisionManifest) { m.MAC[0] ^= 1 }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := m
+			tampered.Identities = []Identity{m.Identities[0]}
+			tampered.Identities[0].PubKey = append([]byte(nil), m.Identities[0].PubKey...)
+			tampered.MAC = append([]byte(nil), m.MAC...)
+			tt.tamper(&tampered)
+
+			ok, err := VerifyProvisionManifest(tampered, verifierPriv)
+			if err != nil {
+				t.Fatalf("VerifyProvisionManifest: %v", err)
+			}
+			if ok != tt.ok {
+				t.Errorf("VerifyProvisionManifest: got %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// ErrPubKeyLength is returned by NormalizePubKey when the public key
+// is not 32 bytes.
+var ErrPubKeyLength = errors.New("public key is not 32 bytes")
+
+// ErrSmallOrderPubKey is returned by NormalizePubKey when the public
+// key is a point of small order, with which ECDH results in a known
+// shared secret.
+var ErrSmallOrderPubKey = errors.New("public key is a small order point")
+
+// ErrZeroPeerKey is returned by DoECDH when the peer's public key is
+// all zeroes, see IsZeroPubKey.
+var ErrZeroPeerKey = errors.New("peer public key is all zeroes")
+
+// curve25519P is the prime 2^255 - 19.
+var curve25519P = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+
+// NormalizePubKey validates raw as an X25519 public key, for example
+// before storing a peer's key, and returns it in canonical form. It
+// fails with ErrPubKeyLength if raw is not 32 bytes, and with
+// ErrSmallOrderPubKey if it is a point of small order, which
+// DoECDH would reject with ErrSmallOrderPoint.
+//
+// The canonical form has the unused most significant bit cleared,
+// and the u-coordinate reduced modulo 2^255 - 19, as X25519 does
+// (RFC 7748). Any 32 byte value is otherwise a valid public key,
+// since X25519 works on the curve or its twist alike.
+func NormalizePubKey(raw []byte) ([32]byte, error) {
+	var pub [32]byte
+
+	if len(raw) != len(pub) {
+		return pub, fmt.Errorf("%w: %d bytes", ErrPubKeyLength, len(raw))
+	}
+
+	// big.Int is big-endian, the key little-endian
+	var be [32]byte
+	for i := range raw {
+		be[31-i] = raw[i]
+	}
+	be[0] &= 0x7f
+
+	u := new(big.Int).SetBytes(be[:])
+	u.Mod(u, curve25519P)
+	u.FillBytes(be[:])
+	for i := range be {
+		pub[31-i] = be[i]
+	}
+
+	if isLowOrderPoint(pub[:]) {
+		return [32]byte{}, ErrSmallOrderPubKey
+	}
+
+	return pub, nil
+}
+
+// IsZeroPubKey reports whether pub is 32 bytes of zeroes, which is
+// never a legitimate public key, but is what a buggy peer may send.
+// DoECDH rejects it with ErrZeroPeerKey before talking to the TKey,
+// unless WithZeroPeerKeyCheck(false) is used. This is only the most
+// common case of a key of small order; NormalizePubKey checks for all
+// of them.
+func IsZeroPubKey(pub []byte) bool {
+	return len(pub) == 32 && isAllZero(pub)
+}
+
+// WithZeroPeerKeyCheck sets whether DoECDH rejects an all-zero peer
+// public key (see IsZeroPubKey) with ErrZeroPeerKey up front, without
+// spending a round-trip to the TKey, and maybe a touch. The default is
+// to check. Without the check, the device app computes the all-zero
+// shared secret, which DoECDH then rejects with ErrSmallOrderPoint.
+func WithZeroPeerKeyCheck(check bool) Option {
+	return func(o *options) {
+		o.noZeroPeerKeyCheck = !check
+	}
+}
+
+// SortPubKeys returns a copy of keys sorted in ascending lexicographic
+// order of their 32 bytes (as compared by bytes.Compare), leaving keys
+// as is. Parties combining secrets from several ECDH operations, or
+// hashing a set of public keys, can use this to agree on the order
+// without further coordination.
+func SortPubKeys(keys [][32]byte) [][32]byte {
+	sorted := append([][32]byte(nil), keys...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+
+	return sorted
+}
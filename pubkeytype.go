@@ -0,0 +1,100 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Key types, as reported by a device app with the TypedPubKey
+// capability in the response to cmdGetPubKey.
+const (
+	KeyTypeX25519 = byte(0x01)
+	KeyTypeX448   = byte(0x02)
+)
+
+// ErrUnsupportedKeyType is returned by GetPubKey when the device app
+// returns a public key that is not an X25519 key.
+var ErrUnsupportedKeyType = errors.New("unsupported public key type")
+
+// GetPubKeyTyped is like GetPubKey, but also returns the type of the
+// public key, one of the KeyType constants. A device app with the
+// TypedPubKey capability (see GetCapabilities) prefixes the public
+// key with a key type byte and a length byte, which are parsed here.
+// For other device apps, which only do X25519, KeyTypeX25519 is
+// returned. Unknown key types are returned as is, for the caller to
+// reject.
+//
+// Unlike GetPubKey, which only looks at capabilities that are already
+// cached (for example by Warmup), this gets them from the device app
+// if needed.
+func (x X25519) GetPubKeyTyped(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, opts ...Option) (keyType byte, pub []byte, err error) {
+	return x.getPubKey(domainString, userSecret, touchByte(requireTouch), true, opts)
+}
+
+// getPubKey gets the public key, parsing a key type if the device app
+// has the TypedPubKey capability. If fetchCaps is false, capabilities
+// are only used if already cached, so that device apps without
+// cmdGetCapabilities are not sent it.
+func (x X25519) getPubKey(domainString string, userSecret [UserSecretSize]byte, touchByte byte, fetchCaps bool, opts []Option) (byte, []byte, error) {
+	x = x.with(opts)
+
+	x, span := x.startSpan(SpanGetPubKey, cmdGetPubKey, touchByte != 0)
+	keyType, pub, err := x.doGetPubKey(domainString, userSecret, touchByte, fetchCaps)
+	if err == nil {
+		err = x.verifyPubKey(domainString, userSecret, touchByte, pub)
+	}
+	span.end(err)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return keyType, pub, nil
+}
+
+func (x X25519) doGetPubKey(domainString string, userSecret [UserSecretSize]byte, touchByte byte, fetchCaps bool) (byte, []byte, error) {
+	x, release, err := x.acquire()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer release()
+
+	if err = x.checkDomainLimit(domainString, userSecret); err != nil {
+		return 0, nil, err
+	}
+
+	caps := x.knownCapabilities()
+	if fetchCaps {
+		if caps, err = x.cachedCapabilities(); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	data := keyParameters(domainString, userSecret, touchByte)
+
+	var rx []byte
+	err = x.withReadTimeout(PhaseCommand, x.commandTimeout(false), func() error {
+		var err error
+		rx, err = x.sendCommand(cmdGetPubKey, data, rspGetPubKey)
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if !caps.TypedPubKey {
+		return KeyTypeX25519, rx[:32], nil
+	}
+
+	keyType, keyLen := rx[0], int(rx[1])
+	if keyLen > len(rx)-2 {
+		return 0, nil, fmt.Errorf("public key length %d too large", keyLen)
+	}
+	if keyType == KeyTypeX25519 && keyLen != 32 {
+		return 0, nil, fmt.Errorf("wrong X25519 public key length %d", keyLen)
+	}
+
+	return keyType, rx[2 : 2+keyLen], nil
+}
@@ -0,0 +1,111 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBusy is returned when a command can not be queued for the TKey,
+// because WithMaxQueue commands are already waiting for it.
+var ErrBusy = errors.New("device busy, too many commands queued")
+
+// WithMaxQueue limits how many commands may be waiting for the TKey
+// while it is busy with another one. A command that would exceed the
+// limit fails right away with ErrBusy. The default, 0, means no
+// limit.
+//
+// Commands on an X25519 (and all copies of it) are always sent one at
+// a time, in the order they were called, so an X25519 can be shared
+// by goroutines. Since a command requiring touch can take long, a
+// busy server should use this to fail fast rather than pile up
+// waiting goroutines.
+func WithMaxQueue(n int) Option {
+	return func(o *options) {
+		o.maxQueue = n
+	}
+}
+
+// acquire waits for the TKey to be free, and returns a copy of x
+// holding it. Commands on the copy, including nested ones, do not
+// wait again. release must be called when done with the TKey. If x
+// already holds the TKey, release does nothing.
+//
+// Waiting is given up, and the place in the queue left, if the
+// context of WithContext is done.
+func (x X25519) acquire() (X25519, func(), error) {
+	if x.held {
+		return x, func() {}, nil
+	}
+
+	// Do not take the TKey, even if free, for a command already
+	// given up
+	ctx := x.opts.context()
+	if err := ctx.Err(); err != nil {
+		return x, nil, err
+	}
+
+	st := x.st
+	st.mu.Lock()
+	if !st.busy {
+		st.busy = true
+		st.mu.Unlock()
+	} else {
+		if x.opts.maxQueue > 0 && len(st.waiters) >= x.opts.maxQueue {
+			st.mu.Unlock()
+			return x, nil, ErrBusy
+		}
+		ready := make(chan struct{})
+		st.waiters = append(st.waiters, ready)
+		st.mu.Unlock()
+
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			st.mu.Lock()
+			queued := false
+			for i, w := range st.waiters {
+				if w == ready {
+					st.waiters = append(st.waiters[:i], st.waiters[i+1:]...)
+					queued = true
+					break
+				}
+			}
+			st.mu.Unlock()
+			if !queued {
+				// We were handed the TKey after all
+				st.release()
+			}
+			return x, nil, fmt.Errorf("waiting for device: %w", ctx.Err())
+		}
+	}
+
+	x.held = true
+
+	st.mu.Lock()
+	st.lastOp = OperationInfo{}
+	st.mu.Unlock()
+
+	if err := x.checkDeviceBinding(); err != nil {
+		st.release()
+		return x, nil, err
+	}
+
+	return x, st.release, nil
+}
+
+// release hands the TKey to the first waiting command, if any.
+func (st *state) release() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if len(st.waiters) == 0 {
+		st.busy = false
+		return
+	}
+
+	close(st.waiters[0])
+	st.waiters = st.waiters[1:]
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until n commands are waiting for the TKey of x.
+func waitQueued(t *testing.T, x X25519, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		x.st.mu.Lock()
+		queued := len(x.st.waiters)
+		x.st.mu.Unlock()
+		if queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d commands queued, want %d", queued, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueueOrder(t *testing.T) {
+	x := newX25519(&fakeDevice{cdi: 1}, nil)
+
+	_, release, err := x.acquire()
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	const n = 5
+	order := make(chan int, n)
+	for i := 0; i < n; i++ {
+		i := i
+		go func() {
+			_, release, err := x.acquire()
+			if err != nil {
+				t.Errorf("acquire %d: %v", i, err)
+				return
+			}
+			order <- i
+			release()
+		}()
+		waitQueued(t, x, i+1)
+	}
+
+	release()
+	for want := 0; want < n; want++ {
+		if got := <-order; got != want {
+			t.Fatalf("command %d got the TKey, want %d", got, want)
+		}
+	}
+
+	// The queue is empty, so the TKey is free again
+	if _, release, err = x.acquire(); err != nil {
+		t.Fatalf("acquire after queue: %v", err)
+	}
+	release()
+}
+
+func TestQueueFull(t *testing.T) {
+	var userSecret [UserSecretSize]byte
+
+	x := newX25519(&fakeDevice{cdi: 1}, []Option{WithMaxQueue(1)})
+
+	_, release, err := x.acquire()
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := x.GetPubKey("test", userSecret, false)
+		done <- err
+	}()
+	waitQueued(t, x, 1)
+
+	if _, err = x.GetPubKey("test", userSecret, false); !errors.Is(err, ErrBusy) {
+		t.Fatalf("GetPubKey with full queue: got %v, want ErrBusy", err)
+	}
+
+	release()
+	if err = <-done; err != nil {
+		t.Fatalf("queued GetPubKey: %v", err)
+	}
+}
+
+func TestQueueCancel(t *testing.T) {
+	var userSecret [UserSecretSize]byte
+
+	x := newX25519(&fakeDevice{cdi: 1}, nil)
+
+	_, release, err := x.acquire()
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := x.GetPubKey("test", userSecret, false, WithContext(ctx))
+		cancelled <- err
+	}()
+	waitQueued(t, x, 1)
+
+	done := make(chan error)
+	go func() {
+		_, err := x.GetPubKey("test", userSecret, false)
+		done <- err
+	}()
+	waitQueued(t, x, 2)
+
+	cancel()
+	if err = <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled GetPubKey: got %v, want context.Canceled", err)
+	}
+	// The cancelled command left the queue, the other one is still
+	// in it
+	waitQueued(t, x, 1)
+
+	release()
+	if err = <-done; err != nil {
+		t.Fatalf("queued GetPubKey: %v", err)
+	}
+
+	// Already cancelled, the TKey is not taken even though free
+	if _, err = x.GetPubKey("test", userSecret, false, WithContext(ctx)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetPubKey with cancelled context: got %v, want context.Canceled", err)
+	}
+	x.st.mu.Lock()
+	busy := x.st.busy
+	x.st.mu.Unlock()
+	if busy {
+		t.Fatalf("TKey busy after cancelled command")
+	}
+}
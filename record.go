@@ -0,0 +1,194 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// A capture, as written by Record and read by ReplayFrom, is text
+// with one frame per line. Each line is "tx" or "rx" followed by a
+// space and the whole frame (including the header byte) in hex. A
+// not-OK response is recorded as "rx-nok" followed by its header byte
+// in hex, and a read timeout as just "rx-timeout". Any other failed
+// read is recorded as "rx-error" followed by the error message.
+const (
+	captureTx        = "tx"
+	captureRx        = "rx"
+	captureRxNOK     = "rx-nok"
+	captureRxTimeout = "rx-timeout"
+	captureRxError   = "rx-error"
+)
+
+type recorder struct {
+	Transport
+	w io.Writer
+}
+
+func (r *recorder) Write(d []byte) error {
+	if _, err := fmt.Fprintf(r.w, "%s %x\n", captureTx, d); err != nil {
+		return fmt.Errorf("record: %w", err)
+	}
+
+	return r.Transport.Write(d)
+}
+
+func (r *recorder) ReadFrame(expectedResp tkeyclient.Cmd, expectedID int) ([]byte, tkeyclient.FramingHdr, error) {
+	rx, hdr, err := r.Transport.ReadFrame(expectedResp, expectedID)
+
+	var recErr error
+	switch {
+	case errors.Is(err, tkeyclient.ErrResponseStatusNotOK):
+		_, recErr = fmt.Fprintf(r.w, "%s %02x\n", captureRxNOK, formatFramingHdr(hdr))
+	case isReadTimeout(err):
+		_, recErr = fmt.Fprintf(r.w, "%s\n", captureRxTimeout)
+	case err != nil:
+		_, recErr = fmt.Fprintf(r.w, "%s %s\n", captureRxError, err)
+	default:
+		_, recErr = fmt.Fprintf(r.w, "%s %x\n", captureRx, rx)
+	}
+	if recErr != nil {
+		return nil, hdr, fmt.Errorf("record: %w", recErr)
+	}
+
+	return rx, hdr, err
+}
+
+type captureLine struct {
+	kind  string
+	frame []byte
+	err   string
+}
+
+type replayer struct {
+	lines []captureLine
+}
+
+// ReplayFrom returns an X25519 that, instead of talking to a TKey,
+// replays a capture previously written using Record. Each frame
+// written must be identical to the next recorded tx frame, and each
+// read returns the next recorded rx frame (or error). A recorded
+// not-OK response is returned as tkeyclient.ErrResponseStatusNotOK,
+// and a recorded read timeout as the same error tkeyclient returns on
+// a timeout. This lets tests exercise the real protocol without
+// hardware.
+func ReplayFrom(r io.Reader, opts ...Option) (X25519, error) {
+	var rp replayer
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		kind, rest, _ := strings.Cut(scanner.Text(), " ")
+
+		line := captureLine{kind: kind}
+		switch kind {
+		case captureTx, captureRx:
+			frame, err := hex.DecodeString(rest)
+			if err != nil {
+				return X25519{}, fmt.Errorf("capture line %d: %w", lineNo, err)
+			}
+			if len(frame) == 0 {
+				return X25519{}, fmt.Errorf("capture line %d: empty frame", lineNo)
+			}
+			line.frame = frame
+		case captureRxNOK:
+			hdr, err := hex.DecodeString(rest)
+			if err != nil {
+				return X25519{}, fmt.Errorf("capture line %d: %w", lineNo, err)
+			}
+			if len(hdr) != 1 {
+				return X25519{}, fmt.Errorf("capture line %d: header is %d bytes", lineNo, len(hdr))
+			}
+			line.frame = hdr
+		case captureRxTimeout:
+		case captureRxError:
+			line.err = rest
+		default:
+			return X25519{}, fmt.Errorf("capture line %d: unknown kind %q", lineNo, kind)
+		}
+		rp.lines = append(rp.lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return X25519{}, fmt.Errorf("reading capture: %w", err)
+	}
+
+	return newX25519(&rp, opts), nil
+}
+
+func (rp *replayer) next() (captureLine, error) {
+	if len(rp.lines) == 0 {
+		return captureLine{}, errors.New("replay: capture exhausted")
+	}
+	line := rp.lines[0]
+	rp.lines = rp.lines[1:]
+
+	return line, nil
+}
+
+func (rp *replayer) Write(d []byte) error {
+	line, err := rp.next()
+	if err != nil {
+		return err
+	}
+	if line.kind != captureTx {
+		return fmt.Errorf("replay: write, but capture has %s", line.kind)
+	}
+	if !bytes.Equal(d, line.frame) {
+		return fmt.Errorf("replay: written frame differs from capture")
+	}
+
+	return nil
+}
+
+func (rp *replayer) ReadFrame(expectedResp tkeyclient.Cmd, expectedID int) ([]byte, tkeyclient.FramingHdr, error) {
+	line, err := rp.next()
+	if err != nil {
+		return nil, tkeyclient.FramingHdr{}, err
+	}
+
+	switch line.kind {
+	case captureRxNOK:
+		return nil, parseFramingHdr(line.frame[0]), tkeyclient.ErrResponseStatusNotOK
+	case captureRxTimeout:
+		return nil, tkeyclient.FramingHdr{}, errors.New("Read timeout")
+	case captureRxError:
+		return nil, tkeyclient.FramingHdr{}, errors.New(line.err)
+	case captureRx:
+	default:
+		return nil, tkeyclient.FramingHdr{}, fmt.Errorf("replay: read, but capture has %s", line.kind)
+	}
+
+	rx := line.frame
+	hdr := parseFramingHdr(rx[0])
+
+	switch {
+	case hdr.CmdLen != expectedResp.CmdLen():
+		return nil, hdr, fmt.Errorf("replay: expected cmdlen %v, got %v", expectedResp.CmdLen(), hdr.CmdLen)
+	case hdr.Endpoint != expectedResp.Endpoint():
+		return nil, hdr, fmt.Errorf("replay: message not meant for us: dest %v", hdr.Endpoint)
+	case hdr.ID != byte(expectedID):
+		return nil, hdr, fmt.Errorf("replay: expected ID %d, got %d", expectedID, hdr.ID)
+	case len(rx) != 1+hdr.CmdLen.Bytelen():
+		return nil, hdr, fmt.Errorf("replay: frame length %d does not match header", len(rx))
+	case rx[1] != expectedResp.Code():
+		return rx, hdr, fmt.Errorf("replay: expected cmd code 0x%x (%s), got 0x%x", expectedResp.Code(), expectedResp, rx[1])
+	}
+
+	return rx, hdr, nil
+}
+
+func (rp *replayer) SetReadTimeout(int) error {
+	return nil
+}
+
+func (rp *replayer) Close() error {
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// recordSession runs a session against x that includes a not-OK
+// response (GetCapabilities, unknown to fakeDevice) and a read
+// timeout (Drain, with nothing left to read), and returns the public
+// key and shared secret from it.
+func recordSession(t *testing.T, x X25519) ([]byte, []byte) {
+	t.Helper()
+
+	var userSecret [UserSecretSize]byte
+
+	pub, err := x.GetPubKey("test", userSecret, false)
+	if err != nil {
+		t.Fatalf("GetPubKey: %v", err)
+	}
+
+	caps, err := x.GetCapabilities()
+	if err != nil {
+		t.Fatalf("GetCapabilities: %v", err)
+	}
+	if caps.Known {
+		t.Fatalf("GetCapabilities: got known capabilities, want not-OK response")
+	}
+
+	if err = x.Drain(); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+
+	shared, err := x.DoECDH("test", userSecret, false, mustKey(t, "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f"))
+	if err != nil {
+		t.Fatalf("DoECDH: %v", err)
+	}
+
+	return pub, shared
+}
+
+func TestRecordReplay(t *testing.T) {
+	var capture bytes.Buffer
+	wantPub, wantShared := recordSession(t, NewWithTransport(&fakeDevice{cdi: 1}, Record(&capture)))
+
+	for _, kind := range []string{captureTx, captureRx, captureRxNOK, captureRxTimeout} {
+		if !strings.Contains(capture.String(), "\n"+kind+" ") && !strings.Contains(capture.String(), "\n"+kind+"\n") {
+			t.Fatalf("capture has no %s line:\n%s", kind, capture.String())
+		}
+	}
+
+	x, err := ReplayFrom(bytes.NewReader(capture.Bytes()))
+	if err != nil {
+		t.Fatalf("ReplayFrom: %v", err)
+	}
+
+	gotPub, gotShared := recordSession(t, x)
+	if !bytes.Equal(gotPub, wantPub) {
+		t.Fatalf("replayed GetPubKey: got %x, want %x", gotPub, wantPub)
+	}
+	if !bytes.Equal(gotShared, wantShared) {
+		t.Fatalf("replayed DoECDH: got %x, want %x", gotShared, wantShared)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	var userSecret [UserSecretSize]byte
+
+	var capture bytes.Buffer
+	x := NewWithTransport(&fakeDevice{cdi: 1}, Record(&capture))
+	if _, err := x.GetPubKey("test", userSecret, false); err != nil {
+		t.Fatalf("GetPubKey: %v", err)
+	}
+	if _, err := x.GetCapabilities(); err != nil {
+		t.Fatalf("GetCapabilities: %v", err)
+	}
+	lines := strings.SplitAfter(capture.String(), "\n")
+
+	for _, tt := range []struct {
+		name    string
+		capture string
+		run     func(x X25519) error
+		wantErr string
+	}{
+		{
+			name:    "other domain",
+			capture: capture.String(),
+			run: func(x X25519) error {
+				_, err := x.GetPubKey("other", userSecret, false)
+				return err
+			},
+			wantErr: "replay: written frame differs from capture",
+		},
+		{
+			name:    "exhausted",
+			capture: capture.String(),
+			run: func(x X25519) error {
+				if _, err := x.GetPubKey("test", userSecret, false); err != nil {
+					return err
+				}
+				if _, err := x.GetCapabilities(); err != nil {
+					return err
+				}
+				_, err := x.GetPubKey("test", userSecret, false)
+				return err
+			},
+			wantErr: "replay: capture exhausted",
+		},
+		{
+			name:    "response missing",
+			capture: lines[0] + lines[2],
+			run: func(x X25519) error {
+				_, err := x.GetPubKey("test", userSecret, false)
+				return err
+			},
+			wantErr: "replay: read, but capture has tx",
+		},
+		{
+			name:    "command missing",
+			capture: lines[1],
+			run: func(x X25519) error {
+				_, err := x.GetPubKey("test", userSecret, false)
+				return err
+			},
+			wantErr: "replay: write, but capture has rx",
+		},
+		{
+			name:    "wrong response",
+			capture: lines[2] + lines[1],
+			run: func(x X25519) error {
+				_, err := x.GetCapabilities()
+				return err
+			},
+			wantErr: "replay: expected cmdlen",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			x, err := ReplayFrom(strings.NewReader(tt.capture))
+			if err != nil {
+				t.Fatalf("ReplayFrom: %v", err)
+			}
+
+			err = tt.run(x)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReplayBadCapture(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		capture string
+	}{
+		{"unknown kind", "rx-ok 00\n"},
+		{"bad hex", "tx 0g\n"},
+		{"empty frame", "rx \n"},
+		{"long header", "rx-nok 0000\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReplayFrom(strings.NewReader(tt.capture)); err == nil {
+				t.Fatalf("ReplayFrom succeeded")
+			}
+		})
+	}
+}
+
+func TestReplayReadError(t *testing.T) {
+	var userSecret [UserSecretSize]byte
+
+	var capture bytes.Buffer
+	x := NewWithTransport(&fakeDevice{cdi: 1}, Record(&capture))
+	if _, err := x.GetPubKey("test", userSecret, false); err != nil {
+		t.Fatalf("GetPubKey: %v", err)
+	}
+	tx, _, _ := strings.Cut(capture.String(), "\n")
+
+	x, err := ReplayFrom(strings.NewReader(tx + "\n" + captureRxError + " ReadFull: unplugged\n"))
+	if err != nil {
+		t.Fatalf("ReplayFrom: %v", err)
+	}
+
+	_, err = x.GetPubKey("test", userSecret, false)
+	if err == nil || !strings.Contains(err.Error(), "ReadFull: unplugged") {
+		t.Fatalf("got error %v, want recorded read error", err)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// RekeyStreamInfo is the first part of the HKDF info used for
+// deriving the keys of a rekeying stream.
+const RekeyStreamInfo = "tkeyx25519 rekeying stream"
+
+// A rekeying stream, as written by NewRekeyingEncryptor and read by
+// NewRekeyingDecryptor, is framed like a stream from
+// NewStreamEncryptor: chunks of StreamChunkSize bytes of plaintext,
+// each sealed with ChaCha20-Poly1305, with the chunk number and a last
+// chunk flag as nonce. The key is changed every rekeyInterval bytes
+// of plaintext, that is every rekeyInterval/StreamChunkSize chunks.
+// The key for epoch e (the chunk number divided by the chunks per
+// key) is derived using HKDF-SHA256 with the shared secret as input
+// keying material, no salt, and RekeyStreamInfo followed by e as a
+// big-endian uint64 as info. Learning one key thus reveals nothing
+// about the chunks sealed under the others.
+
+// NewRekeyingEncryptor returns a WriteCloser that encrypts the stream
+// written to it under keys derived from shared, changing key every
+// rekeyInterval bytes, and writes it to dst. rekeyInterval must be a
+// positive multiple of StreamChunkSize. shared is typically the
+// result of one DoECDH, and the keys derived from it must only ever be
+// used for one stream. Close must be called to write the last chunk,
+// after which the copy of shared kept is zeroed; it does not close
+// dst.
+func NewRekeyingEncryptor(shared []byte, rekeyInterval int, dst io.Writer) (io.WriteCloser, error) {
+	rekey, err := newRekeyer(shared, rekeyInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamWriter{rekey: rekey, dst: dst}, nil
+}
+
+// NewRekeyingDecryptor returns a Reader that decrypts the stream read
+// from src, written by NewRekeyingEncryptor with the same shared and
+// rekeyInterval. As with NewStreamDecryptor, the caller must not act
+// on the plaintext until Read has returned io.EOF.
+func NewRekeyingDecryptor(shared []byte, rekeyInterval int, src io.Reader) (io.Reader, error) {
+	rekey, err := newRekeyer(shared, rekeyInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamReader{rekey: rekey, src: src}, nil
+}
+
+// rekeyer derives the keys of a rekeying stream.
+type rekeyer struct {
+	shared       []byte
+	chunksPerKey uint64
+}
+
+func newRekeyer(shared []byte, rekeyInterval int) (*rekeyer, error) {
+	if rekeyInterval <= 0 || rekeyInterval%StreamChunkSize != 0 {
+		return nil, fmt.Errorf("rekey interval %d is not a positive multiple of %d", rekeyInterval, StreamChunkSize)
+	}
+
+	return &rekeyer{
+		shared:       append([]byte(nil), shared...),
+		chunksPerKey: uint64(rekeyInterval / StreamChunkSize),
+	}, nil
+}
+
+// aeadFor returns the AEAD for chunk number counter if it is the
+// first chunk of an epoch, and nil otherwise.
+func (r *rekeyer) aeadFor(counter uint64) (cipher.AEAD, error) {
+	if counter%r.chunksPerKey != 0 {
+		return nil, nil
+	}
+
+	info := binary.BigEndian.AppendUint64([]byte(RekeyStreamInfo), counter/r.chunksPerKey)
+	key := hkdfSHA256(r.shared, nil, string(info), chacha20poly1305.KeySize)
+	defer wipe(key)
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("chacha20poly1305.New: %w", err)
+	}
+
+	return aead, nil
+}
+
+func (r *rekeyer) wipe() {
+	wipe(r.shared)
+}
@@ -0,0 +1,77 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// DeviceReport is a snapshot of a TKey and its device app, see Report.
+// It holds no secrets.
+type DeviceReport struct {
+	// AppName and AppVersion are the device app's name (see
+	// GetAppNameVersion) and version.
+	AppName    string `json:"app_name"`
+	AppVersion uint32 `json:"app_version"`
+	// CapabilitiesKnown tells whether the device app reported its
+	// capabilities; CapabilityFlags are the flags it reported.
+	CapabilitiesKnown bool   `json:"capabilities_known"`
+	CapabilityFlags   uint32 `json:"capability_flags"`
+	// DeviceID is the device ID (see GetDeviceID) in hex, or empty
+	// if it could not be got.
+	DeviceID string `json:"device_id,omitempty"`
+	// RoundTripMicros is the round-trip time of getting the name
+	// and version (see Ping), in microseconds.
+	RoundTripMicros int64 `json:"round_trip_us"`
+	// Errors holds why optional fields are missing, if any are.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Report collects a DeviceReport, for inventory and monitoring of many
+// TKeys; it is meant to be serialized as JSON. It fails only if the
+// device app does not answer GetAppNameVersion. Capabilities and the
+// device ID are optional: if getting them fails, they are left out,
+// and the reason added to Errors. It never requires touch.
+//
+// Note that the device ID links the report to the TKey, like a serial
+// number would, see GetDeviceID.
+func (x X25519) Report(opts ...Option) (DeviceReport, error) {
+	x = x.with(opts)
+
+	x, release, err := x.acquire()
+	if err != nil {
+		return DeviceReport{}, err
+	}
+	defer release()
+
+	var report DeviceReport
+
+	start := time.Now()
+	nameVer, err := x.GetAppNameVersion()
+	if err != nil {
+		return DeviceReport{}, err
+	}
+	report.RoundTripMicros = time.Since(start).Microseconds()
+	report.AppName = appName(nameVer)
+	report.AppVersion = nameVer.Version
+
+	caps, err := x.cachedCapabilities()
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("capabilities: %v", err))
+	} else {
+		report.CapabilitiesKnown = caps.Known
+		report.CapabilityFlags = caps.Flags
+	}
+
+	id, err := x.GetDeviceID()
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("device ID: %v", err))
+	} else {
+		report.DeviceID = hex.EncodeToString(id)
+	}
+
+	return report, nil
+}
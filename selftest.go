@@ -0,0 +1,130 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// loopbackDomain is the domain of the key used by LoopbackTest. The
+// userSecret used is all zeroes.
+const loopbackDomain = "tkeyx25519 loopback test"
+
+// ErrLoopbackMismatch is returned by LoopbackTest when the shared
+// secret computed by the device app differs from the one computed in
+// software.
+var ErrLoopbackMismatch = errors.New("device app shared secret does not match software")
+
+// LoopbackTest checks the whole ECDH path of the device app, without
+// requiring touch. It gets the device's public key for a fixed test
+// domain and an all-zero userSecret, generates an ephemeral key pair
+// in software, and has the device app do DoECDH with the ephemeral
+// public key. The same shared secret is then computed in software,
+// from the ephemeral private key and the device's public key. If
+// they differ, ErrLoopbackMismatch is returned, wrapped with details.
+func (x X25519) LoopbackTest(opts ...Option) error {
+	x = x.with(opts)
+
+	var zeroSecret [UserSecretSize]byte
+
+	devicePub, err := x.GetPubKey(loopbackDomain, zeroSecret, false)
+	if err != nil {
+		return err
+	}
+
+	ephemeralPriv, ephemeralPub, err := generateEphemeral(x.opts.random())
+	if err != nil {
+		return err
+	}
+	defer wipe(ephemeralPriv)
+
+	var theirPubKey [32]byte
+	copy(theirPubKey[:], ephemeralPub)
+
+	deviceShared, err := x.DoECDH(loopbackDomain, zeroSecret, false, theirPubKey)
+	if err != nil {
+		return err
+	}
+	defer wipe(deviceShared)
+
+	softwareShared, err := curve25519.X25519(ephemeralPriv, devicePub)
+	if err != nil {
+		return fmt.Errorf("X25519: %w", err)
+	}
+	defer wipe(softwareShared)
+
+	if subtle.ConstantTimeCompare(deviceShared, softwareShared) != 1 {
+		return fmt.Errorf("%w: DoECDH with ephemeral public key %x, device public key %x",
+			ErrLoopbackMismatch, ephemeralPub, devicePub)
+	}
+
+	return nil
+}
+
+// ErrNotDeterministic is matched (using errors.Is) by the
+// *DeterminismError returned by VerifyDeterminism.
+var ErrNotDeterministic = errors.New("public key not deterministic")
+
+// DeterminismError is returned by VerifyDeterminism when a public key
+// differs from the first one.
+type DeterminismError struct {
+	Iteration int    // The iteration, from 1, giving Got
+	First     []byte // The public key of the first iteration
+	Got       []byte // The differing public key
+}
+
+func (e *DeterminismError) Error() string {
+	return fmt.Sprintf("public key of iteration %d is %x, first was %x", e.Iteration, e.Got, e.First)
+}
+
+func (e *DeterminismError) Is(target error) bool {
+	return target == ErrNotDeterministic
+}
+
+// VerifyDeterminism checks that the TKey gives the same public key
+// for domainString, userSecret, and requireTouch every time, also
+// across power cycles, showing that the key derivation is stable. It
+// does iterations iterations, each calling open to get a connection
+// to the TKey, getting the public key, and closing the connection.
+// open is where the caller power-cycles the TKey between iterations
+// (it is called with the iteration, from 1), by asking the user to
+// replug it, or using a hook such as a switchable USB hub, and
+// reloads the device app. The app must be the same binary, loaded
+// with the same USS, or the key differs by design.
+//
+// If a key differs from the first, a *DeterminismError (matching
+// ErrNotDeterministic) with both keys is returned.
+func VerifyDeterminism(open func(iteration int) (X25519, error), domainString string, userSecret [UserSecretSize]byte, requireTouch bool, iterations int) error {
+	var first []byte
+
+	for i := 1; i <= iterations; i++ {
+		x, err := open(i)
+		if err != nil {
+			return fmt.Errorf("open, iteration %d: %w", i, err)
+		}
+
+		pub, err := x.GetPubKey(domainString, userSecret, requireTouch)
+		closeErr := x.Close()
+		if err != nil {
+			return fmt.Errorf("iteration %d: %w", i, err)
+		}
+		if closeErr != nil {
+			return fmt.Errorf("iteration %d: %w", i, closeErr)
+		}
+
+		if first == nil {
+			first = pub
+			continue
+		}
+		if subtle.ConstantTimeCompare(pub, first) != 1 {
+			return &DeterminismError{Iteration: i, First: first, Got: pub}
+		}
+	}
+
+	return nil
+}
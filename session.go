@@ -0,0 +1,31 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+// Session is an X25519 holding exclusive use of the TKey, see
+// WithExclusive. It has all the methods of X25519.
+type Session struct {
+	X25519
+}
+
+// WithExclusive runs f with exclusive use of the TKey, waiting for
+// its turn like any command (see WithMaxQueue). The commands f does
+// using tx are not interleaved with commands from other goroutines,
+// so for example getting a public key and doing ECDH with it can be
+// done as one indivisible operation. Use of the TKey is released when
+// f returns, and f's error is returned.
+//
+// tx must not be used after f returns, nor from other goroutines than
+// the one running f, as it would then bypass the queue.
+func (x X25519) WithExclusive(f func(tx *Session) error, opts ...Option) error {
+	x = x.with(opts)
+
+	x, release, err := x.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return f(&Session{x})
+}
@@ -0,0 +1,151 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// NewForSimulator connects to a TKey emulated by QEMU, and returns an
+// X25519 talking to it. The X25519 device app must already be loaded,
+// for example by passing it to QEMU, or using Provision on a
+// tkeyclient.TillitisKey connected to the same path.
+//
+// path is either a Unix domain socket, when QEMU is run with the
+// TKey's serial port as a socket chardev (like "-chardev
+// socket,id=c,path=tkey.sock,server=on"), or the pseudo terminal QEMU
+// prints when run with a pty chardev. A pseudo terminal is opened
+// using tkeyclient, just like a real TKey. Commands, timeouts and
+// errors behave the same as against a real TKey, so the same code
+// can be tested in CI without hardware.
+func NewForSimulator(path string, opts ...Option) (X25519, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return X25519{}, fmt.Errorf("Stat: %w", err)
+	}
+
+	if fi.Mode()&os.ModeSocket == 0 {
+		tk := tkeyclient.New()
+		if err = tk.Connect(path); err != nil {
+			return X25519{}, fmt.Errorf("Connect: %w", err)
+		}
+
+		return New(tk, opts...), nil
+	}
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	conn, err := net.DialTimeout("unix", path, o.timeouts.Connect)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return X25519{}, &TimeoutError{Phase: PhaseConnect, Err: err}
+		}
+		return X25519{}, fmt.Errorf("Dial: %w", err)
+	}
+
+	return newX25519(&socketTransport{conn: conn}, opts), nil
+}
+
+// socketTransport talks to a TKey over a stream socket, doing the
+// framing the same way as tkeyclient does over a serial port.
+type socketTransport struct {
+	conn        net.Conn
+	readTimeout time.Duration
+}
+
+func (s *socketTransport) Write(d []byte) error {
+	if _, err := s.conn.Write(d); err != nil {
+		return fmt.Errorf("Write: %w", err)
+	}
+
+	return nil
+}
+
+// SetReadTimeout sets the timeout for waiting for the header byte of
+// a frame, like for a serial port.
+func (s *socketTransport) SetReadTimeout(seconds int) error {
+	s.readTimeout = time.Duration(seconds) * time.Second
+
+	return nil
+}
+
+func (s *socketTransport) Close() error {
+	if err := s.conn.Close(); err != nil {
+		return fmt.Errorf("Close: %w", err)
+	}
+
+	return nil
+}
+
+func (s *socketTransport) ReadFrame(expectedResp tkeyclient.Cmd, expectedID int) ([]byte, tkeyclient.FramingHdr, error) {
+	var deadline time.Time
+	if s.readTimeout > 0 {
+		deadline = time.Now().Add(s.readTimeout)
+	}
+	if err := s.conn.SetReadDeadline(deadline); err != nil {
+		return nil, tkeyclient.FramingHdr{}, fmt.Errorf("SetReadDeadline: %w", err)
+	}
+
+	rxHdr := make([]byte, 1)
+	if _, err := io.ReadFull(s.conn, rxHdr); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, tkeyclient.FramingHdr{}, fmt.Errorf("Read timeout")
+		}
+		return nil, tkeyclient.FramingHdr{}, fmt.Errorf("Read: %w", err)
+	}
+
+	// The rest of the frame is read without timeout
+	if err := s.conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, tkeyclient.FramingHdr{}, fmt.Errorf("SetReadDeadline: %w", err)
+	}
+
+	if rxHdr[0]&0b1000_0000 != 0 {
+		return nil, tkeyclient.FramingHdr{}, fmt.Errorf("Couldn't parse framing header: reserved bit #7 is not zero")
+	}
+	hdr := parseFramingHdr(rxHdr[0])
+
+	if hdr.ResponseNotOK {
+		rest := make([]byte, hdr.CmdLen.Bytelen())
+		if _, err := io.ReadFull(s.conn, rest); err != nil {
+			return nil, hdr, fmt.Errorf("%w; ReadFull: %v", tkeyclient.ErrResponseStatusNotOK, err)
+		}
+		return nil, hdr, tkeyclient.ErrResponseStatusNotOK
+	}
+
+	if hdr.CmdLen != expectedResp.CmdLen() {
+		return nil, hdr, fmt.Errorf("Expected cmdlen %v (%d bytes), got %v (%d bytes)",
+			expectedResp.CmdLen(), expectedResp.CmdLen().Bytelen(),
+			hdr.CmdLen, hdr.CmdLen.Bytelen())
+	}
+	if hdr.Endpoint != expectedResp.Endpoint() {
+		return nil, hdr, fmt.Errorf("Message not meant for us: dest %v", hdr.Endpoint)
+	}
+	if hdr.ID != byte(expectedID) {
+		return nil, hdr, fmt.Errorf("Expected ID %d, got %d", expectedID, hdr.ID)
+	}
+
+	rx := make([]byte, 1+expectedResp.CmdLen().Bytelen())
+	rx[0] = rxHdr[0]
+	if _, err := io.ReadFull(s.conn, rx[1:]); err != nil {
+		return nil, hdr, fmt.Errorf("ReadFull: %w", err)
+	}
+
+	if rx[1] != expectedResp.Code() {
+		return rx, hdr, fmt.Errorf("Expected cmd code 0x%x (%s), got 0x%x", expectedResp.Code(), expectedResp, rx[1])
+	}
+
+	return rx, hdr, nil
+}
@@ -0,0 +1,58 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// CheckSharedConsistency checks, in software, that myPub is the
+// public key of myPrivKey, and that shared is the X25519 shared
+// secret of myPrivKey and peerPub. It returns nil if so.
+//
+// This needs the private key, so it can not be used with keys on a
+// TKey. It is meant for the host side of a protocol, for example an
+// ephemeral key generated in software by one party whose peer uses
+// DoECDH, and for tests. There it can catch bugs in custom protocols
+// built on this package.
+func CheckSharedConsistency(myPrivKey [32]byte, myPub []byte, peerPub []byte, shared []byte) error {
+	pub, err := curve25519.X25519(myPrivKey[:], curve25519.Basepoint)
+	if err != nil {
+		return fmt.Errorf("X25519: %w", err)
+	}
+	if subtle.ConstantTimeCompare(pub, myPub) != 1 {
+		return errors.New("public key does not match private key")
+	}
+
+	expected, err := curve25519.X25519(myPrivKey[:], peerPub)
+	if err != nil {
+		return fmt.Errorf("X25519: %w", err)
+	}
+	if subtle.ConstantTimeCompare(expected, shared) != 1 {
+		return errors.New("shared secret does not match keys")
+	}
+
+	return nil
+}
+
+// generateEphemeral generates an X25519 key pair in software, reading
+// the private key from random.
+func generateEphemeral(random io.Reader) (privKey []byte, pubKey []byte, err error) {
+	privKey = make([]byte, curve25519.ScalarSize)
+	if _, err = io.ReadFull(random, privKey); err != nil {
+		return nil, nil, fmt.Errorf("generating ephemeral key: %w", err)
+	}
+
+	pubKey, err = curve25519.X25519(privKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, fmt.Errorf("X25519: %w", err)
+	}
+
+	return privKey, pubKey, nil
+}
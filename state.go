@@ -0,0 +1,45 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"sync"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// state is what an X25519 learns while talking to the TKey. It is
+// shared by all copies of an X25519.
+//
+// The mutex only guards access to the fields, and is not held while
+// talking to the TKey.
+type state struct {
+	mu   sync.Mutex
+	caps *Capabilities // Cached by cachedCapabilities
+
+	appVersionChecked bool
+	appVersionErr     error
+
+	transcript []Exchange // Recorded when WithTranscript is used
+
+	busy    bool            // A command is using the TKey, see acquire
+	waiters []chan struct{} // Closed in turn to hand over the TKey
+
+	wedged bool // Given up on by the watchdog
+
+	boundDeviceID []byte // Remembered when WithDeviceBinding is used
+
+	// Found as expected per key parameters, see WithExpectedPubKey
+	pubKeysVerified map[[32]byte][]byte
+
+	frameID int // Last frame id used, see WithFrameResync
+
+	lastOp  OperationInfo           // See LastOperationInfo
+	nameVer *tkeyclient.NameVersion // Last seen, for LastOperationInfo
+
+	// Domains used per hashed userSecret, see WithMaxDomains
+	domains map[[32]byte]map[[32]byte]struct{}
+
+	readTimeout int // Last set on the connection, see timeoutTracker
+}
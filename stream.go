@@ -0,0 +1,277 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+// StreamInfo is the HKDF info label used for deriving the stream key.
+const StreamInfo = "tkeyx25519 stream"
+
+// StreamChunkSize is the size of the plaintext chunks of a stream.
+const StreamChunkSize = 64 * 1024
+
+// A stream, as written by NewStreamEncryptor and read by
+// NewStreamDecryptor, is encrypted with ChaCha20-Poly1305 using a key
+// derived with HKDF-SHA256 from the X25519 shared secret of the
+// sender's ephemeral key and the recipient's key, with the ephemeral
+// public key as salt, and StreamInfo as info.
+//
+// The plaintext is split into chunks of StreamChunkSize bytes, the
+// last one possibly shorter (and empty only if the whole plaintext
+// is). Each chunk is sealed on its own, so the ciphertext is a
+// sequence of chunks of StreamChunkSize+16 bytes, the last one
+// shorter. The nonce of each chunk is its 0-based number as an
+// 11-byte big-endian integer, followed by a byte which is 1 for the
+// last chunk and 0 otherwise. This makes truncating or reordering the
+// stream detectable.
+const (
+	streamTagSize      = chacha20poly1305.Overhead
+	streamEncChunkSize = StreamChunkSize + streamTagSize
+	streamLastFlag     = 0x01
+)
+
+// NewStreamEncryptor generates an ephemeral key, and returns its
+// public key together with a WriteCloser that encrypts the stream
+// written to it for recipientPub, writing it to dst. The ephemeral
+// public key must be passed along to the recipient. Close must be
+// called to write the last chunk; it does not close dst. Of opts,
+// only WithRand applies.
+func NewStreamEncryptor(recipientPub []byte, dst io.Writer, opts ...Option) ([32]byte, io.WriteCloser, error) {
+	var ephemeralPub [32]byte
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ephemeralPriv, pub, err := generateEphemeral(o.random())
+	if err != nil {
+		return ephemeralPub, nil, err
+	}
+	defer wipe(ephemeralPriv)
+	copy(ephemeralPub[:], pub)
+
+	shared, err := curve25519.X25519(ephemeralPriv, recipientPub)
+	if err != nil {
+		return ephemeralPub, nil, fmt.Errorf("X25519: %w", err)
+	}
+
+	aead, err := streamAEAD(shared, ephemeralPub)
+	if err != nil {
+		return ephemeralPub, nil, err
+	}
+
+	return ephemeralPub, &streamWriter{aead: aead, dst: dst}, nil
+}
+
+// NewStreamDecryptor does ECDH once (see DoECDH) with ephemeralPub,
+// the sender's public key from NewStreamEncryptor, and returns a
+// Reader that decrypts the stream read from src. Only one touch is
+// needed, however long the stream is.
+//
+// Data is only returned after the chunk it is in has been
+// authenticated, but a stream that is cut short is only detected at
+// its end. The caller must not act on the plaintext until Read has
+// returned io.EOF.
+func (x X25519) NewStreamDecryptor(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, ephemeralPub [32]byte, src io.Reader, opts ...Option) (io.Reader, error) {
+	shared, err := x.DoECDH(domainString, userSecret, requireTouch, ephemeralPub, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := streamAEAD(shared, ephemeralPub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamReader{aead: aead, src: src}, nil
+}
+
+func streamAEAD(shared []byte, ephemeralPub [32]byte) (cipher.AEAD, error) {
+	key := hkdfSHA256(shared, ephemeralPub[:], StreamInfo, chacha20poly1305.KeySize)
+	defer wipe(key)
+
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("chacha20poly1305.New: %w", err)
+	}
+
+	return aead, nil
+}
+
+func streamNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	for i := 0; i < 8; i++ {
+		nonce[10-i] = byte(counter >> (8 * i))
+	}
+	if last {
+		nonce[11] = streamLastFlag
+	}
+
+	return nonce
+}
+
+type streamWriter struct {
+	aead    cipher.AEAD
+	rekey   *rekeyer // Set for a rekeying stream
+	dst     io.Writer
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed stream")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// Only seal a full chunk once more data arrives, as the last
+		// chunk must be marked as such
+		if len(w.buf) == StreamChunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := StreamChunkSize - len(w.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+func (w *streamWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	return w.flush(true)
+}
+
+func (w *streamWriter) flush(last bool) error {
+	if w.rekey != nil {
+		aead, err := w.rekey.aeadFor(w.counter)
+		if err != nil {
+			return err
+		}
+		if aead != nil {
+			w.aead = aead
+		}
+		if last {
+			defer w.rekey.wipe()
+		}
+	}
+
+	sealed := w.aead.Seal(nil, streamNonce(w.counter, last), w.buf, nil)
+	if _, err := w.dst.Write(sealed); err != nil {
+		return fmt.Errorf("Write: %w", err)
+	}
+
+	w.counter++
+	w.buf = w.buf[:0]
+
+	return nil
+}
+
+type streamReader struct {
+	aead    cipher.AEAD
+	rekey   *rekeyer // Set for a rekeying stream
+	src     io.Reader
+	buf     []byte // Ciphertext, with room for one byte of lookahead
+	carry   int    // Lookahead bytes at the start of buf
+	plain   []byte
+	counter uint64
+	last    bool
+	err     error
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.readChunk()
+		if r.err == nil && r.last {
+			r.err = io.EOF
+		}
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+
+	return n, nil
+}
+
+// readChunk reads and decrypts the next chunk into r.plain, setting
+// r.last if it was the last one.
+func (r *streamReader) readChunk() error {
+	if r.buf == nil {
+		r.buf = make([]byte, streamEncChunkSize+1)
+	}
+
+	// Read a whole chunk, and one more byte to tell whether it is
+	// the last one
+	n, err := io.ReadFull(r.src, r.buf[r.carry:])
+	n += r.carry
+
+	last := false
+	switch {
+	case err == nil:
+		n = streamEncChunkSize
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	default:
+		return fmt.Errorf("Read: %w", err)
+	}
+
+	if n < streamTagSize {
+		return errors.New("stream truncated")
+	}
+
+	if r.rekey != nil {
+		aead, err := r.rekey.aeadFor(r.counter)
+		if err != nil {
+			return err
+		}
+		if aead != nil {
+			r.aead = aead
+		}
+		if last {
+			defer r.rekey.wipe()
+		}
+	}
+
+	plain, err := r.aead.Open(r.buf[:0:0], streamNonce(r.counter, last), r.buf[:n], nil)
+	if err != nil {
+		return fmt.Errorf("chunk %d: %w", r.counter, err)
+	}
+	r.plain = plain
+	r.counter++
+
+	if last {
+		r.last = true
+		return nil
+	}
+
+	r.buf[0] = r.buf[streamEncChunkSize]
+	r.carry = 1
+
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+// streamTestSetup returns an X25519 with a fake device, and a stream
+// of plaintext encrypted to its key, with the ephemeral public key.
+func streamTestSetup(t *testing.T, plaintext []byte) (X25519, [32]byte, []byte) {
+	t.Helper()
+
+	var userSecret [UserSecretSize]byte
+	x := newX25519(&fakeDevice{cdi: 1}, nil)
+
+	pub, err := x.GetPubKey("test", userSecret, false)
+	if err != nil {
+		t.Fatalf("GetPubKey: %v", err)
+	}
+
+	var ciphertext bytes.Buffer
+	ephemeralPub, w, err := NewStreamEncryptor(pub, &ciphertext)
+	if err != nil {
+		t.Fatalf("NewStreamEncryptor: %v", err)
+	}
+	if _, err = w.Write(plaintext); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	return x, ephemeralPub, ciphertext.Bytes()
+}
+
+func streamTestDecrypt(x X25519, ephemeralPub [32]byte, ciphertext []byte) ([]byte, error) {
+	var userSecret [UserSecretSize]byte
+
+	r, err := x.NewStreamDecryptor("test", userSecret, false, ephemeralPub, bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 5} {
+		plaintext := make([]byte, size)
+		if _, err := rand.Read(plaintext); err != nil {
+			t.Fatal(err)
+		}
+
+		x, ephemeralPub, ciphertext := streamTestSetup(t, plaintext)
+
+		got, err := streamTestDecrypt(x, ephemeralPub, ciphertext)
+		if err != nil {
+			t.Errorf("%d bytes: %v", size, err)
+			continue
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("%d bytes: plaintext differs", size)
+		}
+	}
+}
+
+func TestStreamTampered(t *testing.T) {
+	plaintext := make([]byte, 2*StreamChunkSize+100)
+	x, ephemeralPub, ciphertext := streamTestSetup(t, plaintext)
+
+	chunk := func(i int) []byte {
+		end := (i + 1) * streamEncChunkSize
+		if end > len(ciphertext) {
+			end = len(ciphertext)
+		}
+		return ciphertext[i*streamEncChunkSize : end]
+	}
+	join := func(chunks ...[]byte) []byte {
+		return bytes.Join(chunks, nil)
+	}
+
+	tests := []struct {
+		name       string
+		ciphertext []byte
+	}{
+		{"empty", nil},
+		{"last chunk dropped", join(chunk(0), chunk(1))},
+		{"cut inside last chunk", ciphertext[:len(ciphertext)-1]},
+		{"cut inside first chunk", ciphertext[:streamEncChunkSize/2]},
+		{"chunks reordered", join(chunk(1), chunk(0), chunk(2))},
+		{"chunk replayed", join(chunk(0), chunk(0), chunk(1), chunk(2))},
+		{"chunk flipped", append(join(chunk(0), chunk(1)), append([]byte{chunk(2)[0] ^ 1}, chunk(2)[1:]...)...)},
+		{"trailing data", join(ciphertext, []byte{0})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := streamTestDecrypt(x, ephemeralPub, tt.ciphertext); err == nil {
+				t.Error("decrypting tampered stream: got no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Timeouts are the timeouts for the phases of talking to a TKey, see
+// WithTimeouts. A zero timeout means the default for that phase.
+// Timeouts are rounded up to whole seconds, except Connect.
+type Timeouts struct {
+	// Connect is the timeout for connecting, where this package
+	// does so (NewForSimulator with a socket). The default is no
+	// timeout.
+	Connect time.Duration
+	// Command is the timeout for the response to commands not
+	// waiting for touch. The default is 2 seconds for
+	// GetAppNameVersion and GetCapabilities, which are used to
+	// probe the device app, and no timeout for other commands.
+	Command time.Duration
+	// Touch is the timeout for the response to DoECDH with
+	// requireTouch, that is for the user to touch the TKey. The
+	// default is to wait for as long as the device app does.
+	Touch time.Duration
+}
+
+// TimeoutPhase is the phase of talking to a TKey that timed out.
+type TimeoutPhase int
+
+const (
+	PhaseConnect TimeoutPhase = iota
+	PhaseCommand
+	PhaseTouch
+)
+
+func (p TimeoutPhase) String() string {
+	switch p {
+	case PhaseConnect:
+		return "connect"
+	case PhaseCommand:
+		return "command"
+	case PhaseTouch:
+		return "touch"
+	}
+	return fmt.Sprintf("TimeoutPhase(%d)", int(p))
+}
+
+// TimeoutError is returned when a timeout (see Timeouts) expires,
+// telling in which phase. Note that a touch timeout reported by the
+// device app itself is a *ResponseStatusNotOKError with code
+// StatusTouchTimeout instead.
+type TimeoutError struct {
+	Phase TimeoutPhase
+	Err   error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout: %v", e.Phase, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// WithTimeouts sets the timeouts of all phases, see Timeouts. It
+// replaces any timeout set by WithTouchTimeout.
+func WithTimeouts(t Timeouts) Option {
+	return func(o *options) {
+		o.timeouts = t
+	}
+}
+
+// commandTimeout returns the read timeout in seconds for a command
+// not waiting for touch. probe is true for commands probing the
+// device app, which get a short timeout by default.
+func (x X25519) commandTimeout(probe bool) int {
+	if x.opts.timeouts.Command > 0 {
+		return durationToSeconds(x.opts.timeouts.Command)
+	}
+	if probe {
+		return nameVersionTimeout
+	}
+	return 0
+}
+
+// isReadTimeout tells whether err is, or wraps, tkeyclient's
+// ReadFrame timing out, which it does not signal with a typed error.
+func isReadTimeout(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if err.Error() == "Read timeout" {
+			return true
+		}
+	}
+	return false
+}
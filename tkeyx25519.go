@@ -6,8 +6,11 @@ package tkeyx25519
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/tillitis/tkeyclient"
 	"golang.org/x/crypto/blake2s"
@@ -15,8 +18,21 @@ import (
 
 const UserSecretSize = 32
 
+// ErrSmallOrderPoint is returned by DoECDH when the shared secret is
+// all-zero, which happens when theirPubKey is a small order point.
+var ErrSmallOrderPoint = errors.New("result is all-zero due to small order point in input")
+
+// ErrTouchUnsupported is returned by DoECDH when requireTouch is
+// true, but the device app reports that it does not enforce touch.
+var ErrTouchUnsupported = errors.New("touch required, but not supported by device app")
+
+// ErrLowOrderResult is returned by DoECDH when using WithStrictECDH,
+// and the shared secret is one of the small order points.
+var ErrLowOrderResult = errors.New("result is a small order point")
+
 type ResponseStatusNotOKError struct {
 	code byte
+	raw  []byte
 }
 
 func (e *ResponseStatusNotOKError) Error() string {
@@ -27,6 +43,12 @@ func (e *ResponseStatusNotOKError) Code() byte {
 	return e.code
 }
 
+// RawResponse returns the whole response frame, including the header
+// byte, if WithRawErrorResponses was used, and nil otherwise.
+func (e *ResponseStatusNotOKError) RawResponse() []byte {
+	return e.raw
+}
+
 const (
 	StatusOK           = byte(0)
 	StatusWrongCmdLen  = byte(1)
@@ -34,12 +56,14 @@ const (
 )
 
 var (
-	cmdGetNameVersion = appCmd{0x01, "cmdGetNameVersion", tkeyclient.CmdLen1}
-	rspGetNameVersion = appCmd{0x02, "rspGetNameVersion", tkeyclient.CmdLen32}
-	cmdGetPubKey      = appCmd{0x03, "cmdGetPubKey", tkeyclient.CmdLen128}
-	rspGetPubKey      = appCmd{0x04, "rspGetPubKey", tkeyclient.CmdLen128}
-	cmdDoECDH         = appCmd{0x05, "cmdDoECDH", tkeyclient.CmdLen128}
-	rspDoECDH         = appCmd{0x06, "rspDoECDH", tkeyclient.CmdLen128}
+	cmdGetNameVersion  = appCmd{0x01, "cmdGetNameVersion", tkeyclient.CmdLen1}
+	rspGetNameVersion  = appCmd{0x02, "rspGetNameVersion", tkeyclient.CmdLen32}
+	cmdGetPubKey       = appCmd{0x03, "cmdGetPubKey", tkeyclient.CmdLen128}
+	rspGetPubKey       = appCmd{0x04, "rspGetPubKey", tkeyclient.CmdLen128}
+	cmdDoECDH          = appCmd{0x05, "cmdDoECDH", tkeyclient.CmdLen128}
+	rspDoECDH          = appCmd{0x06, "rspDoECDH", tkeyclient.CmdLen128}
+	cmdGetCapabilities = appCmd{0x07, "cmdGetCapabilities", tkeyclient.CmdLen1}
+	rspGetCapabilities = appCmd{0x08, "rspGetCapabilities", tkeyclient.CmdLen32}
 )
 
 type appCmd struct {
@@ -65,45 +89,200 @@ func (c appCmd) String() string {
 }
 
 type X25519 struct {
-	tk *tkeyclient.TillitisKey // A connection to a TKey
+	tk   Transport // A connection to a TKey
+	opts options
+	st   *state
+	held bool // Holds the TKey, see acquire
+}
+
+func New(tk *tkeyclient.TillitisKey, opts ...Option) X25519 {
+	return newX25519(tk, opts)
+}
+
+// NewWithTransport is like New, but talks to the TKey using t, for
+// example a connection over a network bridge to a TKey plugged in
+// elsewhere. The protocol is the same as over a local serial port.
+func NewWithTransport(t Transport, opts ...Option) X25519 {
+	return newX25519(t, opts)
 }
 
-func New(tk *tkeyclient.TillitisKey) X25519 {
+func newX25519(tk Transport, opts []Option) X25519 {
 	var x25519 X25519
 
-	x25519.tk = tk
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.record != nil {
+		tk = &recorder{Transport: tk, w: o.record}
+	}
+	x25519.st = &state{}
+	x25519.tk = &timeoutTracker{Transport: tk, st: x25519.st}
+	x25519.opts = o
 
 	return x25519
 }
 
-// Close closes the connection to the TKey
+// Close closes the connection to the TKey, and zeroes the userSecret
+// set by WithDefaultUserSecret, if any.
 func (x X25519) Close() error {
+	x.wipeDefaultUserSecret()
+
 	if err := x.tk.Close(); err != nil {
 		return fmt.Errorf("tk.Close: %w", err)
 	}
 	return nil
 }
 
+// SetReadTimeout sets the read timeout of the connection to the TKey,
+// 0 seconds meaning no timeout. This is mostly useful together with
+// WithManualTimeouts.
+func (x X25519) SetReadTimeout(seconds int) error {
+	if err := x.tk.SetReadTimeout(seconds); err != nil {
+		return fmt.Errorf("SetReadTimeout: %w", err)
+	}
+	return nil
+}
+
 // GetAppNameVersion talks to the device app running on the TKey,
 // getting its name and version. A timeout is used to avoid hanging if
 // the device is running an app which does not handle the command, or
-// is in firmware mode.
-func (x X25519) GetAppNameVersion() (*tkeyclient.NameVersion, error) {
-	if err := x.tk.SetReadTimeout(2); err != nil {
-		return nil, fmt.Errorf("SetReadTimeout: %w", err)
-	}
+// is in firmware mode, see Timeouts.
+func (x X25519) GetAppNameVersion(opts ...Option) (*tkeyclient.NameVersion, error) {
+	x = x.with(opts)
 
-	rx, err := x.sendCommand(cmdGetNameVersion, bytes.Buffer{}, rspGetNameVersion)
+	x, release, err := x.acquire()
 	if err != nil {
 		return nil, err
 	}
+	defer release()
+
+	var rx []byte
+	err = x.withReadTimeout(PhaseCommand, x.commandTimeout(true), func() error {
+		var err error
+		rx, err = x.sendCommand(cmdGetNameVersion, bytes.Buffer{}, rspGetNameVersion)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nameVer := &tkeyclient.NameVersion{}
+	nameVer.Unpack(rx[:12])
+	x.noteNameVersion(nameVer)
+
+	return nameVer, nil
+}
 
-	if err = x.tk.SetReadTimeout(0); err != nil {
-		return nil, fmt.Errorf("SetReadTimeout: %w", err)
+// InspectNameVersion is like GetAppNameVersion, but guarantees that
+// the read timeout of the connection is left as it was found, for
+// callers that do not own the connection's timeout policy. tkeyclient
+// has no way to query the timeout, so the one last set through this
+// X25519 (by SetReadTimeout or a command) is what it is restored to,
+// by a deferred reset that runs however the command ends. A timeout
+// set directly on the tkeyclient.TillitisKey or Transport is not
+// known, and is not restored. The short timeout of GetAppNameVersion
+// is always used, also with WithManualTimeouts or WithTimeouts, and
+// neither the watchdog nor WithFlushBetweenCommands apply.
+func (x X25519) InspectNameVersion(opts ...Option) (*tkeyclient.NameVersion, error) {
+	x = x.with(opts)
+	x.opts.flushBetweenCommands = false
+
+	x, release, err := x.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	x.st.mu.Lock()
+	found := x.st.readTimeout
+	x.st.mu.Unlock()
+
+	var rx []byte
+	err = withReadTimeoutRestore(x.tk, nameVersionTimeout, found, func() error {
+		var err error
+		rx, err = x.sendCommand(cmdGetNameVersion, bytes.Buffer{}, rspGetNameVersion)
+		return err
+	})
+	if isReadTimeout(err) {
+		return nil, &TimeoutError{Phase: PhaseCommand, Err: err}
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	nameVer := &tkeyclient.NameVersion{}
 	nameVer.Unpack(rx[:12])
+	x.noteNameVersion(nameVer)
+
+	return nameVer, nil
+}
+
+// Ping measures the round-trip time of getting the device app's name
+// and version, see GetAppNameVersion. It never requires touch, and
+// can be used repeatedly as a health check of the connection.
+func (x X25519) Ping(opts ...Option) (time.Duration, error) {
+	start := time.Now()
+
+	if _, err := x.GetAppNameVersion(opts...); err != nil {
+		return 0, err
+	}
+
+	return time.Since(start), nil
+}
+
+// Warmup primes the connection right after opening it, so that the
+// first real command is fast. It gets the device app's name and
+// version, with the short timeout of GetAppNameVersion, checks it if
+// WithRequiredAppVersion is used, and gets and caches the device
+// app's capabilities (see GetCapabilities). It never requires touch.
+// The name and version are returned.
+//
+// As a best-effort check of the connection, getting the name and
+// version is tried a few times, draining in between (see Drain), as
+// long as the response is garbled (see ErrFrameCorrupt). If it is
+// every time, or draining in between does not get rid of the garbage,
+// ErrLikelySpeedMismatch is returned, since the usual cause is the
+// serial port being opened with the wrong speed.
+func (x X25519) Warmup(opts ...Option) (*tkeyclient.NameVersion, error) {
+	x = x.with(opts)
+
+	x, release, err := x.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var nameVer *tkeyclient.NameVersion
+	for attempt := 1; ; attempt++ {
+		nameVer, err = x.GetAppNameVersion()
+		if !errors.Is(err, ErrFrameCorrupt) {
+			break
+		}
+		if attempt == speedProbeAttempts {
+			return nil, fmt.Errorf("%w: %v", ErrLikelySpeedMismatch, err)
+		}
+		if err = x.drain(); err != nil {
+			if isIOError(err) {
+				return nil, err
+			}
+			// With the wrong speed, garbage may keep arriving
+			// until drain gives up
+			return nil, fmt.Errorf("%w: drain: %v", ErrLikelySpeedMismatch, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = x.checkAppVersion(); err != nil {
+		return nil, err
+	}
+
+	if _, err = x.cachedCapabilities(); err != nil {
+		return nil, err
+	}
 
 	return nameVer, nil
 }
@@ -118,102 +297,335 @@ func (x X25519) GetAppNameVersion() (*tkeyclient.NameVersion, error) {
 // and must be high-entropy random. "requireTouch" indicates whether
 // the TKey should require physical touch when doing ECDH to create
 // the shared secret.
-func (x X25519) GetPubKey(domainString string, userSecret [UserSecretSize]byte, requireTouch bool) ([]byte, error) {
-	data := keyParameters(domainString, userSecret, requireTouch)
+func (x X25519) GetPubKey(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, opts ...Option) ([]byte, error) {
+	return x.GetPubKeyWithTouchPolicy(domainString, userSecret, touchByte(requireTouch), opts...)
+}
 
-	rx, err := x.sendCommand(cmdGetPubKey, data, rspGetPubKey)
+// GetPubKeyWithTouchPolicy is like GetPubKey, but lets the caller set
+// the exact touch byte sent to the device app, instead of 1 or 0 for
+// requireTouch true or false. This is for device app variants that
+// interpret the byte differently, for example as a bitmask of touch
+// policies. Note that the touch byte is part of the key derivation,
+// so every distinct touchByte gives a different key.
+func (x X25519) GetPubKeyWithTouchPolicy(domainString string, userSecret [UserSecretSize]byte, touchByte byte, opts ...Option) ([]byte, error) {
+	keyType, pub, err := x.getPubKey(domainString, userSecret, touchByte, false, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return rx[:32], nil
+	if keyType != KeyTypeX25519 {
+		return nil, fmt.Errorf("%w: 0x%02x", ErrUnsupportedKeyType, keyType)
+	}
+
+	return pub, nil
+}
+
+// GetPubKeyFromReader is like GetPubKey, but reads the userSecret
+// from secretReader, which must provide at least UserSecretSize
+// bytes. This lets the userSecret come from some other secure source
+// without the caller keeping it around. The copy read is zeroed
+// before returning.
+func (x X25519) GetPubKeyFromReader(domainString string, secretReader io.Reader, requireTouch bool, opts ...Option) ([]byte, error) {
+	var userSecret [UserSecretSize]byte
+	defer wipe(userSecret[:])
+
+	if _, err := io.ReadFull(secretReader, userSecret[:]); err != nil {
+		return nil, fmt.Errorf("reading userSecret: %w", err)
+	}
+
+	return x.GetPubKey(domainString, userSecret, requireTouch, opts...)
 }
 
 // DoECDH talks to the X25519 device app running on the TKey to run
 // the ECDH (Elliptic-Curve Diffie-Hellman) function for establishing
 // a shared secret between theirPubKey and a private key. The private
 // key is hashed using the arguments in the same way as is done for
-// GetPubKey.
-func (x X25519) DoECDH(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, theirPubKey [32]byte) ([]byte, error) {
-	data := keyParameters(domainString, userSecret, requireTouch)
-	data.Write(theirPubKey[:])
+// GetPubKey. If requireTouch is true and the device app reports (see
+// GetCapabilities) that it does not enforce touch, ErrTouchUnsupported
+// is returned rather than silently doing ECDH without touch. An
+// all-zero shared secret is rejected with ErrSmallOrderPoint; the
+// check is done in constant time (see IsValidSharedSecret). An
+// all-zero theirPubKey is rejected up front with ErrZeroPeerKey, see
+// WithZeroPeerKeyCheck.
+func (x X25519) DoECDH(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, theirPubKey [32]byte, opts ...Option) ([]byte, error) {
+	x = x.with(opts)
+
+	x, span := x.startSpan(SpanDoECDH, cmdDoECDH, requireTouch)
+	sharedSecret, err := x.doECDH(domainString, userSecret, requireTouch, theirPubKey)
+	span.end(err)
+
+	return sharedSecret, err
+}
+
+func (x X25519) doECDH(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, theirPubKey [32]byte) ([]byte, error) {
+	if !x.opts.noZeroPeerKeyCheck && IsZeroPubKey(theirPubKey[:]) {
+		return nil, ErrZeroPeerKey
+	}
 
-	rx, err := x.sendCommand(cmdDoECDH, data, rspDoECDH)
+	x, release, err := x.acquire()
 	if err != nil {
 		return nil, err
 	}
+	defer release()
+
+	if err := x.checkDomainLimit(domainString, userSecret); err != nil {
+		return nil, err
+	}
+
+	if requireTouch {
+		if err := x.checkTouchSupported(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := x.checkExpectedPubKey(domainString, userSecret, touchByte(requireTouch)); err != nil {
+		return nil, err
+	}
+
+	return x.ecdhCommand(domainString, userSecret, requireTouch, theirPubKey)
+}
+
+// ecdhCommand does the ECDH command of DoECDH, using x, which must
+// hold the TKey, after all checks of the arguments are done. It
+// retries, and falls back from touch, as configured.
+func (x X25519) ecdhCommand(domainString string, userSecret [UserSecretSize]byte, requireTouch bool, theirPubKey [32]byte) ([]byte, error) {
+	data := keyParameters(domainString, userSecret, touchByte(requireTouch))
+	data.Write(theirPubKey[:])
+
+	phase, timeout := PhaseCommand, x.commandTimeout(false)
+	if requireTouch {
+		phase, timeout = PhaseTouch, durationToSeconds(x.opts.timeouts.Touch)
+	}
+
+	var rx []byte
+	for attempt := 1; ; attempt++ {
+		var touchSpan span
+		if requireTouch {
+			x.emit(EventTouchRequired, cmdDoECDH, nil)
+			x.noteOperation(func(info *OperationInfo) { info.Touch = true })
+			_, touchSpan = x.startSpan(SpanTouchWait, cmdDoECDH, requireTouch)
+		}
+		err := x.withReadTimeout(phase, timeout, func() error {
+			var err error
+			rx, err = x.sendCommand(cmdDoECDH, data, rspDoECDH)
+			return err
+		})
+		touchSpan.end(err)
+		if err == nil {
+			break
+		}
+		if !x.retryTouch(err, attempt) {
+			if requireTouch && x.fallBackFromTouch(err) {
+				x.logf("warning: touch timed out, doing ECDH without touch, with a different key")
+				return x.ecdhCommand(domainString, userSecret, false, theirPubKey)
+			}
+			return nil, err
+		}
+		x.logf("touch timed out, retrying (attempt %d)", attempt+1)
+	}
 
 	sharedSecret := rx[:32]
 
-	if isAllZero(sharedSecret) {
-		return nil, errors.New("result is all-zero due to small order point in input")
+	if err := checkSharedSecret(sharedSecret, x.opts.strictECDH); err != nil {
+		return nil, err
 	}
 
 	return sharedSecret, nil
 }
 
-func (x X25519) sendCommand(cmd appCmd, data bytes.Buffer, rsp appCmd) ([]byte, error) {
-	id := 2
-	tx, err := tkeyclient.NewFrameBuf(cmd, id)
-	if err != nil {
-		return nil, fmt.Errorf("NewFrameBuf: %w", err)
+// retryTouch tells whether DoECDH should be retried after attempt
+// failed with err, see WithTouchRetry.
+func (x X25519) retryTouch(err error, attempt int) bool {
+	r := x.opts.touchRetry
+	if r == nil || attempt > r.max {
+		return false
 	}
 
-	// Place data after frame header byte and cmd code byte
-	if data.Len() > (len(tx) - 2) {
-		return nil, fmt.Errorf("data too large (%d > %d-2)", data.Len(), len(tx))
+	if !isTouchTimeout(err) {
+		return false
 	}
-	copy(tx[2:], data.Bytes())
 
-	if err = x.tk.Write(tx); err != nil {
-		return nil, fmt.Errorf("Write: %w", err)
+	return r.shouldRetry == nil || r.shouldRetry(attempt)
+}
+
+// fallBackFromTouch tells whether DoECDH should be done without touch
+// after failing with err, see WithTouchFallback.
+func (x X25519) fallBackFromTouch(err error) bool {
+	return x.opts.touchFallback != nil && isTouchTimeout(err) && x.opts.touchFallback()
+}
+
+// isTouchTimeout tells whether err is the device app reporting that
+// the user did not touch the TKey in time.
+func isTouchTimeout(err error) bool {
+	var notOK *ResponseStatusNotOKError
+	return errors.As(err, &notOK) && notOK.Code() == StatusTouchTimeout
+}
+
+func (x X25519) sendCommand(cmd appCmd, data bytes.Buffer, rsp appCmd) ([]byte, error) {
+	if err := x.opts.context().Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", cmd, err)
 	}
 
-	rx, _, err := x.tk.ReadFrame(rsp, id)
+	if cmd != cmdGetNameVersion {
+		if err := x.checkAppVersion(); err != nil {
+			return nil, err
+		}
+	}
+
+	rx, err := x.exchange(cmd, data.Bytes(), rsp)
 	if err != nil {
-		return nil, fmt.Errorf("ReadFrame: %w", err)
+		return nil, x.errorContext(cmd, err)
 	}
 
 	// This response contains no status code
 	if rsp.code == rspGetNameVersion.code {
 		// Skipping over frame header byte, and rsp code byte
+		x.addExchange(cmd, data.Len(), "ok", len(rx)-2)
+		x.emit(EventResponseReceived, cmd, nil)
 		return rx[2:], nil
 	}
 
 	if rx[2] != StatusOK {
-		return nil, &ResponseStatusNotOKError{code: rx[2]}
+		statusErr := &ResponseStatusNotOKError{code: rx[2]}
+		if x.opts.rawErrorResponses {
+			statusErr.raw = rx
+			x.logf("%s not OK: %x", rsp, rx)
+		}
+		x.addExchange(cmd, data.Len(), fmt.Sprintf("not ok, code: %d", rx[2]), 0)
+		x.emit(EventError, cmd, statusErr)
+		return nil, x.errorContext(cmd, statusErr)
 	}
 
 	// Skipping over frame header byte, rsp code byte, and status byte
+	x.addExchange(cmd, data.Len(), "ok", len(rx)-3)
+	x.emit(EventResponseReceived, cmd, nil)
 	return rx[3:], nil
 }
 
-func keyParameters(domainString string, userSecret [UserSecretSize]byte, requireTouch bool) bytes.Buffer {
+// exchange sends cmd with data to the TKey, and reads the rsp frame,
+// returning all of it. A failure is recorded in the transcript and
+// sent as an event, but success is left to the caller.
+func (x X25519) exchange(cmd tkeyclient.Cmd, data []byte, rsp tkeyclient.Cmd) ([]byte, error) {
+	if err := x.checkWedged(); err != nil {
+		return nil, err
+	}
+
+	// Place data after frame header byte and cmd code byte
+	if len(data) > MaxPayload(cmd) {
+		return nil, fmt.Errorf("data too large (%d > %d)", len(data), MaxPayload(cmd))
+	}
+
+	if x.opts.flushBetweenCommands {
+		if err := x.drain(); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		id := x.frameID()
+		start := time.Now()
+		rx, hdr, err := x.exchangeFrame(cmd, data, rsp, id)
+		x.noteOperation(func(info *OperationInfo) {
+			info.Command = cmd.String()
+			info.RoundTrip = time.Since(start)
+		})
+		if !isStaleFrame(err, hdr, id) || x.opts.frameResync == 0 {
+			return rx, err
+		}
+
+		if attempt == x.opts.frameResync {
+			return nil, fmt.Errorf("%w after %d attempts: %v", ErrFrameDesync, attempt, err)
+		}
+
+		x.logf("stale response to %s (frame id %d, expected %d), resyncing", cmd, hdr.ID, id)
+		if err := x.drain(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// exchangeFrame sends cmd with data in a frame with id, and reads the
+// response. The header of the frame read is returned also on error,
+// if it could be parsed.
+func (x X25519) exchangeFrame(cmd tkeyclient.Cmd, data []byte, rsp tkeyclient.Cmd, id int) ([]byte, tkeyclient.FramingHdr, error) {
+	tx, err := tkeyclient.NewFrameBuf(cmd, id)
+	if err != nil {
+		return nil, tkeyclient.FramingHdr{}, fmt.Errorf("NewFrameBuf: %w", err)
+	}
+	copy(tx[2:], data)
+
+	x.logf("sending %s", cmd)
+	if x.opts.frameTrace {
+		x.logf("tx %s: hdr 0x%02x code 0x%02x, %d bytes payload redacted", cmd, tx[0], tx[1], len(data))
+	}
+	if err = x.tk.Write(tx); err != nil {
+		x.addExchange(cmd, len(data), err.Error(), 0)
+		x.emit(EventError, cmd, err)
+		return nil, tkeyclient.FramingHdr{}, fmt.Errorf("Write: %w", err)
+	}
+	x.emit(EventCommandSent, cmd, nil)
+
+	rx, hdr, err := x.tk.ReadFrame(rsp, id)
+	if isGarbledFrame(err) {
+		err = fmt.Errorf("%w: %v", ErrFrameCorrupt, err)
+	}
+	if err == nil {
+		err = verifyFrame(rx, rsp, id)
+	}
+	if err != nil {
+		x.addExchange(cmd, len(data), err.Error(), 0)
+		x.emit(EventError, cmd, err)
+		return nil, hdr, fmt.Errorf("ReadFrame: %w", err)
+	}
+	x.logf("received %s", rsp)
+	if x.opts.frameTrace {
+		x.logf("rx %s: hdr 0x%02x code 0x%02x", rsp, rx[0], rx[1])
+	}
+
+	return rx, hdr, nil
+}
+
+func keyParameters(domainString string, userSecret [UserSecretSize]byte, touchByte byte) bytes.Buffer {
 	var buf bytes.Buffer
 
+	domain := normalizeDomain(domainString)
+	buf.Write(domain[:])
+
+	buf.Write(userSecret[:])
+
+	buf.WriteByte(touchByte)
+
+	return buf
+}
+
+// normalizeDomain returns the 32 bytes of domain sent to the device
+// app: domainString zero padded, or its blake2s-256 hash if it is
+// longer than 32 bytes.
+func normalizeDomain(domainString string) [32]byte {
 	var domain [32]byte
 	if len(domainString) > 32 {
 		domain = blake2s.Sum256([]byte(domainString))
 	} else {
 		copy(domain[:], []byte(domainString))
 	}
-	buf.Write(domain[:])
 
-	buf.Write(userSecret[:])
+	return domain
+}
 
+func touchByte(requireTouch bool) byte {
 	if requireTouch {
-		buf.WriteByte(1)
-	} else {
-		buf.WriteByte(0)
+		return 1
 	}
-
-	return buf
+	return 0
 }
 
+// isAllZero reports, in constant time, whether bytes are all zero. It
+// looks at every byte whatever their values, and the time taken only
+// depends on the length.
 func isAllZero(bytes []byte) bool {
 	var accu byte
 	for _, b := range bytes {
 		accu |= b
 	}
-	return accu == 0
+	return subtle.ConstantTimeByteEq(accu, 0) == 1
 }
@@ -0,0 +1,81 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// countingTracer counts the spans started, by name.
+type countingTracer map[string]int
+
+func (c countingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	c[name]++
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(string, any) {}
+func (nopSpan) End(error)                {}
+
+func TestTouchFallback(t *testing.T) {
+	userSecret := [UserSecretSize]byte{1}
+	theirPubKey := [32]byte{9}
+
+	noTouch, err := newX25519(&fakeDevice{cdi: 1}, nil).DoECDH("test", userSecret, false, theirPubKey)
+	if err != nil {
+		t.Fatalf("DoECDH without touch: %v", err)
+	}
+	touchPub, err := newX25519(&fakeDevice{cdi: 1}, nil).GetPubKey("test", userSecret, true)
+	if err != nil {
+		t.Fatalf("GetPubKey with touch: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		consent bool
+		opts    []Option
+	}{
+		{"consent", true, nil},
+		{"no consent", false, nil},
+		{"expected touch key", true, []Option{WithExpectedPubKey("test", userSecret, true, touchPub)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asked := 0
+			consent := func() bool {
+				asked++
+				return tt.consent
+			}
+			tracer := countingTracer{}
+			opts := append([]Option{WithTouchFallback(consent), WithTracer(tracer)}, tt.opts...)
+			x := newX25519(&fakeDevice{cdi: 1, touchTimeout: true}, opts)
+
+			shared, err := x.DoECDH("test", userSecret, true, theirPubKey)
+			if asked != 1 {
+				t.Errorf("consent asked %d times, want 1", asked)
+			}
+			if n := tracer[SpanDoECDH]; n != 1 {
+				t.Errorf("%d DoECDH spans, want 1", n)
+			}
+
+			if !tt.consent {
+				if !isTouchTimeout(err) {
+					t.Errorf("DoECDH: got %v, want touch timeout", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DoECDH: %v", err)
+			}
+			if !bytes.Equal(shared, noTouch) {
+				t.Errorf("DoECDH: got %x, want the no-touch shared secret %x", shared, noTouch)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// Tracer starts spans for tracing operations on the TKey, see
+// WithTracer. It is a small subset of a tracing API like
+// OpenTelemetry's, so this package does not depend on one; an adapter
+// is a few lines.
+type Tracer interface {
+	// Start starts a span called name, as a child of any span in
+	// ctx, and returns a context holding the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// SetAttribute sets an attribute of the span. value is a
+	// string, bool, or int.
+	SetAttribute(key string, value any)
+	// End ends the span. err is nil if the operation succeeded.
+	End(err error)
+}
+
+// Names of the spans started when using WithTracer.
+const (
+	SpanGetPubKey = "tkeyx25519.GetPubKey"
+	SpanDoECDH    = "tkeyx25519.DoECDH"
+	SpanTouchWait = "tkeyx25519.TouchWait"
+)
+
+// WithTracer makes GetPubKey and DoECDH (and the operations using
+// them) start a span using tracer, as a child of any span in the
+// context of WithContext. Waiting for touch in DoECDH gets a span of
+// its own. The spans have the attributes "tkey.command" (the command
+// name), "tkey.touch_required", and, when ended, "tkey.status" ("ok",
+// "not ok", or "error") and for a not OK response "tkey.status_code".
+// Keys, secrets, and domains are never put in spans.
+func WithTracer(tracer Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// span is a Span, or nothing when not using WithTracer.
+type span struct {
+	s Span
+}
+
+// startSpan starts a span called name for cmd, and returns a copy of
+// x with the span in its context.
+func (x X25519) startSpan(name string, cmd tkeyclient.Cmd, requireTouch bool) (X25519, span) {
+	if x.opts.tracer == nil {
+		return x, span{}
+	}
+
+	ctx, s := x.opts.tracer.Start(x.opts.context(), name)
+	s.SetAttribute("tkey.command", cmd.String())
+	s.SetAttribute("tkey.touch_required", requireTouch)
+	x.opts.ctx = ctx
+
+	return x, span{s: s}
+}
+
+func (s span) end(err error) {
+	if s.s == nil {
+		return
+	}
+
+	var statusErr *ResponseStatusNotOKError
+	switch {
+	case err == nil:
+		s.s.SetAttribute("tkey.status", "ok")
+	case errors.As(err, &statusErr):
+		s.s.SetAttribute("tkey.status", "not ok")
+		s.s.SetAttribute("tkey.status_code", int(statusErr.Code()))
+	default:
+		s.s.SetAttribute("tkey.status", "error")
+	}
+
+	s.s.End(err)
+}
@@ -0,0 +1,96 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"fmt"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// NewAppCmd returns a command (or response) for use with Transact,
+// with the given code, name (used in logs, transcripts and events),
+// and frame length. Its endpoint is the device app (DestApp).
+func NewAppCmd(code byte, name string, cmdLen tkeyclient.CmdLen) tkeyclient.Cmd {
+	return appCmd{code, name, cmdLen}
+}
+
+// MaxPayload returns the number of payload bytes that fit in a frame
+// of cmd, that is its frame length less the command code byte. Data
+// passed to Transact must not be longer, unless WithChunked is used.
+func MaxPayload(cmd tkeyclient.Cmd) int {
+	return cmd.CmdLen().Bytelen() - 1
+}
+
+// WithEndpointOverride makes Transact send its command to, and expect
+// its response from, endpoint instead of the endpoint of the commands
+// passed (normally DestApp). Only tkeyclient.DestApp and
+// tkeyclient.DestFW are accepted.
+//
+// This is for probing the firmware, or other diagnostics, using the
+// same plumbing as for the device app. The firmware only answers
+// while the TKey is in firmware mode, and commands sent to it can,
+// like loading an app, change the state of the TKey. Know the
+// firmware protocol before using this. Other methods ignore the
+// option.
+func WithEndpointOverride(endpoint tkeyclient.Endpoint) Option {
+	return func(o *options) {
+		o.endpoint = &endpoint
+	}
+}
+
+// endpointCmd is a command with its endpoint overridden.
+type endpointCmd struct {
+	tkeyclient.Cmd
+	endpoint tkeyclient.Endpoint
+}
+
+func (c endpointCmd) Endpoint() tkeyclient.Endpoint {
+	return c.endpoint
+}
+
+// Transact is a low-level way to send any command with data as
+// payload, and read the response rsp, returning the response payload
+// following the response code. No status byte is interpreted, and no
+// device app version check (WithRequiredAppVersion) is done. Use
+// NewAppCmd to create the commands, WithEndpointOverride to talk to
+// another endpoint than that of cmd and rsp, and WithChunked for data
+// longer than MaxPayload.
+func (x X25519) Transact(cmd tkeyclient.Cmd, rsp tkeyclient.Cmd, data []byte, opts ...Option) ([]byte, error) {
+	x = x.with(opts)
+
+	if ep := x.opts.endpoint; ep != nil {
+		if *ep != tkeyclient.DestApp && *ep != tkeyclient.DestFW {
+			return nil, fmt.Errorf("unsupported endpoint override %d", *ep)
+		}
+		cmd = endpointCmd{cmd, *ep}
+		rsp = endpointCmd{rsp, *ep}
+	}
+
+	x, release, err := x.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err = x.opts.context().Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", cmd, err)
+	}
+
+	var rx []byte
+	if x.opts.chunked {
+		rx, err = x.exchangeChunked(cmd, data, rsp)
+	} else {
+		rx, err = x.exchange(cmd, data, rsp)
+	}
+	if err != nil {
+		return nil, x.errorContext(cmd, err)
+	}
+
+	// Skipping over frame header byte, and rsp code byte
+	x.addExchange(cmd, len(data), "ok", len(rx)-2)
+	x.emit(EventResponseReceived, cmd, nil)
+
+	return rx[2:], nil
+}
@@ -0,0 +1,60 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"fmt"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// Exchange is one command sent to the device app and the response to
+// it, as recorded by WithTranscript. Payloads are never included.
+type Exchange struct {
+	Command     string // Name of the command, like "cmdGetPubKey"
+	PayloadLen  int    // Length of the command's payload
+	Status      string // "ok", "not ok, code: N", or the error reading or writing
+	ResponseLen int    // Length of the response's payload, 0 on failure
+}
+
+func (e Exchange) String() string {
+	return fmt.Sprintf("%s (%d bytes): %s (%d bytes)", e.Command, e.PayloadLen, e.Status, e.ResponseLen)
+}
+
+// WithTranscript makes X25519 keep a transcript of the commands it
+// sends and the responses it receives, see Transcript. Only the
+// command names, lengths and statuses are kept, so the transcript can
+// be attached to a bug report without revealing the userSecret or
+// shared secrets.
+func WithTranscript() Option {
+	return func(o *options) {
+		o.transcript = true
+	}
+}
+
+// Transcript returns the exchanges with the TKey recorded so far
+// using WithTranscript, oldest first. The transcript is shared by all
+// copies of x.
+func (x X25519) Transcript() []Exchange {
+	x.st.mu.Lock()
+	defer x.st.mu.Unlock()
+
+	return append([]Exchange(nil), x.st.transcript...)
+}
+
+func (x X25519) addExchange(cmd tkeyclient.Cmd, payloadLen int, status string, responseLen int) {
+	if !x.opts.transcript {
+		return
+	}
+
+	x.st.mu.Lock()
+	defer x.st.mu.Unlock()
+
+	x.st.transcript = append(x.st.transcript, Exchange{
+		Command:     cmd.String(),
+		PayloadLen:  payloadLen,
+		Status:      status,
+		ResponseLen: responseLen,
+	})
+}
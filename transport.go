@@ -0,0 +1,133 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"fmt"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// nameVersionTimeout is the read timeout in seconds used for commands
+// that a device app should answer right away, so we don't hang if
+// the TKey runs some other app or is in firmware mode.
+const nameVersionTimeout = 2
+
+// Transport is what X25519 uses to talk to the TKey. It is satisfied
+// by *tkeyclient.TillitisKey, and lets a recorder, a replayer, or a
+// connection to a TKey elsewhere, like over a network bridge, stand
+// in for it, see NewWithTransport. An implementation must behave like
+// tkeyclient.TillitisKey:
+//
+//   - SetReadTimeout sets the read timeout in seconds, 0 meaning none.
+//   - Write writes a whole frame, as from tkeyclient.NewFrameBuf.
+//   - ReadFrame reads one frame, returning it including the header
+//     byte, and the parsed header. A read timing out gives an error
+//     with the message "Read timeout", and a frame with the not-OK
+//     bit set gives tkeyclient.ErrResponseStatusNotOK, after reading
+//     the rest of the frame.
+//   - Close closes the connection.
+//
+// Commands are sent one at a time (see WithMaxQueue), but Close and
+// SetReadTimeout may also be called by the user of X25519 at any time.
+type Transport interface {
+	SetReadTimeout(seconds int) error
+	Write(d []byte) error
+	ReadFrame(expectedResp tkeyclient.Cmd, expectedID int) ([]byte, tkeyclient.FramingHdr, error)
+	Close() error
+}
+
+// withReadTimeout runs f with the read timeout of the connection set
+// to seconds, and then resets it to no timeout, also if f fails. If
+// seconds is 0, or WithManualTimeouts is used, the timeout is left
+// alone. A read timeout is returned as a *TimeoutError for phase. The
+// watchdog, if any, guards commands without timeout, see
+// WithWatchdog.
+func (x X25519) withReadTimeout(phase TimeoutPhase, seconds int, f func() error) error {
+	seconds, guarded := x.watchdogTimeout(phase, seconds)
+
+	var err error
+	if x.opts.manualTimeouts {
+		err = f()
+	} else {
+		err = withReadTimeout(x.tk, seconds, f)
+	}
+
+	if isReadTimeout(err) {
+		if guarded {
+			if wedgedErr := x.recoverWedged(); wedgedErr != nil {
+				return wedgedErr
+			}
+		}
+		return &TimeoutError{Phase: phase, Err: err}
+	}
+
+	return err
+}
+
+// withReadTimeout runs f with the read timeout of t set to seconds,
+// and then resets it to no timeout, also if f fails. If seconds is 0
+// the timeout is left alone.
+func withReadTimeout(t Transport, seconds int, f func() error) error {
+	return withReadTimeoutRestore(t, seconds, 0, f)
+}
+
+// withReadTimeoutRestore is like withReadTimeout, but resets the
+// timeout to restore seconds instead of to no timeout.
+func withReadTimeoutRestore(t Transport, seconds int, restore int, f func() error) (err error) {
+	if seconds == 0 {
+		return f()
+	}
+
+	if err = t.SetReadTimeout(seconds); err != nil {
+		return fmt.Errorf("SetReadTimeout: %w", err)
+	}
+	defer func() {
+		if resetErr := t.SetReadTimeout(restore); resetErr != nil && err == nil {
+			err = fmt.Errorf("SetReadTimeout: %w", resetErr)
+		}
+	}()
+
+	return f()
+}
+
+// timeoutTracker remembers the read timeout last set on the Transport
+// it wraps, since tkeyclient has no way to query it.
+type timeoutTracker struct {
+	Transport
+	st *state
+}
+
+func (t *timeoutTracker) SetReadTimeout(seconds int) error {
+	if err := t.Transport.SetReadTimeout(seconds); err != nil {
+		return err
+	}
+
+	t.st.mu.Lock()
+	t.st.readTimeout = seconds
+	t.st.mu.Unlock()
+
+	return nil
+}
+
+// parseFramingHdr parses a framing protocol header byte, see
+// tkeyclient.NewFrameBuf for the layout.
+func parseFramingHdr(b byte) tkeyclient.FramingHdr {
+	return tkeyclient.FramingHdr{
+		ID:            (b & 0b0110_0000) >> 5,
+		Endpoint:      tkeyclient.Endpoint((b & 0b0001_1000) >> 3),
+		CmdLen:        tkeyclient.CmdLen(b & 0b0000_0011),
+		ResponseNotOK: (b & 0b0000_0100) != 0,
+	}
+}
+
+// formatFramingHdr is the inverse of parseFramingHdr.
+func formatFramingHdr(hdr tkeyclient.FramingHdr) byte {
+	b := hdr.ID<<5 | byte(hdr.Endpoint)<<3 | byte(hdr.CmdLen)
+	if hdr.ResponseNotOK {
+		b |= 0b0000_0100
+	}
+
+	return b
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// ErrUserSecretChecksumMismatch is returned by LoadOrCreateUserSecret
+// when the userSecret file is corrupt: its checksum does not match
+// the userSecret.
+var ErrUserSecretChecksumMismatch = errors.New("userSecret checksum mismatch")
+
+// A userSecret file, as written by LoadOrCreateUserSecret, is the
+// UserSecretSize bytes of the userSecret, optionally followed by a
+// checksum: a random salt of userSecretSaltSize bytes, and the
+// blake2s-256 hash of userSecretChecksumLabel ("tkeyx25519 userSecret
+// checksum"), the salt, and the userSecret. Being a hash of a 32 byte
+// random value, the checksum reveals nothing usable about the secret.
+const (
+	userSecretChecksumLabel = "tkeyx25519 userSecret checksum"
+	userSecretSaltSize      = 16
+	userSecretFileSize      = UserSecretSize + userSecretSaltSize + blake2s.Size
+)
+
+// WithUserSecretChecksum makes LoadOrCreateUserSecret store a checksum
+// along with a userSecret it creates, and require one in a file it
+// loads. Without this, a checksum is still verified if present.
+func WithUserSecretChecksum() Option {
+	return func(o *options) {
+		o.userSecretChecksum = true
+	}
+}
+
+// LoadOrCreateUserSecret loads the userSecret from the file at path,
+// or, if there is no such file, generates a random one and creates the
+// file with mode 0600. Of opts, WithUserSecretChecksum and WithRand
+// apply.
+//
+// A file with a checksum (see WithUserSecretChecksum) is verified
+// when loaded, failing with ErrUserSecretChecksumMismatch if the
+// checksum does not match, so a corrupted file is caught before it
+// silently gives the wrong keys. A file of the wrong size is rejected
+// too.
+func LoadOrCreateUserSecret(path string, opts ...Option) ([UserSecretSize]byte, error) {
+	var userSecret [UserSecretSize]byte
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return createUserSecret(path, o)
+	}
+	if err != nil {
+		return userSecret, fmt.Errorf("ReadFile: %w", err)
+	}
+	defer wipe(b)
+
+	switch {
+	case len(b) == UserSecretSize && !o.userSecretChecksum:
+	case len(b) == UserSecretSize:
+		return userSecret, errors.New("userSecret file has no checksum")
+	case len(b) == userSecretFileSize:
+		salt, sum := b[UserSecretSize:UserSecretSize+userSecretSaltSize], b[UserSecretSize+userSecretSaltSize:]
+		if subtle.ConstantTimeCompare(sum, userSecretChecksum(salt, b[:UserSecretSize])) != 1 {
+			return userSecret, ErrUserSecretChecksumMismatch
+		}
+	default:
+		return userSecret, fmt.Errorf("userSecret file is %d bytes, expected %d or %d", len(b), UserSecretSize, userSecretFileSize)
+	}
+
+	copy(userSecret[:], b)
+
+	return userSecret, nil
+}
+
+func createUserSecret(path string, o options) ([UserSecretSize]byte, error) {
+	var userSecret [UserSecretSize]byte
+
+	b := make([]byte, UserSecretSize)
+	defer func() { wipe(b) }()
+	if _, err := io.ReadFull(o.random(), b); err != nil {
+		return userSecret, fmt.Errorf("generating userSecret: %w", err)
+	}
+
+	if o.userSecretChecksum {
+		salt := make([]byte, userSecretSaltSize)
+		if _, err := io.ReadFull(o.random(), salt); err != nil {
+			return userSecret, fmt.Errorf("generating salt: %w", err)
+		}
+		checked := append(append(append([]byte(nil), b...), salt...), userSecretChecksum(salt, b)...)
+		wipe(b)
+		b = checked
+	}
+
+	// Not replacing a file created since we looked
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return userSecret, fmt.Errorf("OpenFile: %w", err)
+	}
+	// Not leaving a truncated file behind, which later loads would
+	// reject
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		os.Remove(path)
+		return userSecret, fmt.Errorf("Write: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(path)
+		return userSecret, fmt.Errorf("Close: %w", err)
+	}
+
+	copy(userSecret[:], b)
+
+	return userSecret, nil
+}
+
+func userSecretChecksum(salt []byte, userSecret []byte) []byte {
+	data := append([]byte(userSecretChecksumLabel), salt...)
+	data = append(data, userSecret...)
+	defer wipe(data)
+
+	sum := blake2s.Sum256(data)
+
+	return sum[:]
+}
+
+// AuditUserSecrets compares all the userSecrets with each other, and
+// returns the index pairs [i, j] (with i < j) of those that are
+// equal. Using the same userSecret for several identities with
+// different domains is fine, but reusing it across unrelated
+// applications may not be intended. The comparisons are done in
+// constant time.
+func AuditUserSecrets(userSecrets [][UserSecretSize]byte) [][2]int {
+	var collisions [][2]int
+
+	for i := range userSecrets {
+		for j := i + 1; j < len(userSecrets); j++ {
+			if subtle.ConstantTimeCompare(userSecrets[i][:], userSecrets[j][:]) == 1 {
+				collisions = append(collisions, [2]int{i, j})
+			}
+		}
+	}
+
+	return collisions
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDeviceWedged is returned when the watchdog (see WithWatchdog)
+// found the TKey not responding, and by every command after that.
+var ErrDeviceWedged = errors.New("device not responding, connection given up")
+
+// WithWatchdog makes commands that would otherwise wait forever for a
+// response give up after silence (rounded up to whole seconds). The
+// watchdog then drains the connection (see Drain) and probes the
+// device app with GetAppNameVersion. If the probe succeeds, the
+// command fails with a *TimeoutError. If not, the X25519 (and all its
+// copies) is marked dead, and the command and all later ones fail
+// with ErrDeviceWedged; close it and connect anew to recover, or use
+// WithReconnect to have the watchdog do so.
+//
+// The watchdog does not apply to waiting for touch, which the device
+// app times out by itself, to commands with a timeout of their own
+// (see Timeouts), or when using WithManualTimeouts. The default is no
+// watchdog.
+func WithWatchdog(silence time.Duration) Option {
+	return func(o *options) {
+		o.watchdog = silence
+	}
+}
+
+// WithReconnect makes the watchdog (see WithWatchdog), when the TKey
+// is not responding, call connect for a new connection instead of
+// giving up. connect would typically wait for the TKey to be plugged
+// in again, open it, and load the device app if needed. The old
+// connection is closed, and if the device app responds on the new
+// one, the X25519 (and all its copies) go on using it. The command
+// that timed out still fails with a *TimeoutError, since how far it
+// got can not be known. If connect fails, or the device app does not
+// respond, the X25519 is marked dead, as without WithReconnect.
+//
+// connect must connect to the same TKey, and device app, as before;
+// use WithDeviceBinding to make sure.
+func WithReconnect(connect func() (Transport, error)) Option {
+	return func(o *options) {
+		o.reconnect = connect
+	}
+}
+
+// watchdogTimeout returns the read timeout in seconds to use for a
+// command in phase that would otherwise have seconds as timeout, and
+// whether the watchdog is guarding it.
+func (x X25519) watchdogTimeout(phase TimeoutPhase, seconds int) (int, bool) {
+	if x.opts.watchdog <= 0 || x.opts.manualTimeouts || phase == PhaseTouch || seconds != 0 {
+		return seconds, false
+	}
+
+	return durationToSeconds(x.opts.watchdog), true
+}
+
+// checkWedged returns ErrDeviceWedged if the watchdog has given up on
+// the TKey.
+func (x X25519) checkWedged() error {
+	x.st.mu.Lock()
+	defer x.st.mu.Unlock()
+
+	if x.st.wedged {
+		return ErrDeviceWedged
+	}
+
+	return nil
+}
+
+// recoverWedged is called by the watchdog when a command timed out.
+// It tries to get the device app responding again, reconnecting if
+// using WithReconnect, and marks the X25519 dead if that fails.
+func (x X25519) recoverWedged() error {
+	x.logf("watchdog: no response, probing device")
+
+	err := x.drain()
+	if err == nil {
+		err = x.probe()
+	}
+	if err != nil && x.opts.reconnect != nil {
+		x.logf("watchdog: device not responding, reconnecting: %v", err)
+		err = x.reconnect()
+	}
+	if err == nil {
+		return nil
+	}
+
+	x.logf("watchdog: device not responding, giving up: %v", err)
+	x.st.mu.Lock()
+	x.st.wedged = true
+	x.st.mu.Unlock()
+
+	return ErrDeviceWedged
+}
+
+// probe checks that the device app responds, by getting its name and
+// version.
+func (x X25519) probe() error {
+	return withReadTimeout(x.tk, nameVersionTimeout, func() error {
+		_, err := x.exchange(cmdGetNameVersion, nil, rspGetNameVersion)
+		return err
+	})
+}
+
+// reconnect replaces the connection of x, shared by all its copies,
+// with one from WithReconnect, and probes the device app on it.
+func (x X25519) reconnect() error {
+	tracker, ok := x.tk.(*timeoutTracker)
+	if !ok {
+		return errors.New("connection can not be replaced")
+	}
+
+	tk, err := x.opts.reconnect()
+	if err != nil {
+		return fmt.Errorf("reconnect: %w", err)
+	}
+	if x.opts.record != nil {
+		tk = &recorder{Transport: tk, w: x.opts.record}
+	}
+
+	if err = tracker.Transport.Close(); err != nil {
+		x.logf("watchdog: closing old connection: %v", err)
+	}
+	tracker.Transport = tk
+
+	// Nothing is known about the new connection
+	x.st.mu.Lock()
+	x.st.readTimeout = 0
+	x.st.mu.Unlock()
+
+	return x.probe()
+}
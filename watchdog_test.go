@@ -0,0 +1,98 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tillitis/tkeyclient"
+)
+
+// deadDevice never responds: every read times out.
+type deadDevice struct {
+	fakeDevice
+	closed bool
+}
+
+func (d *deadDevice) ReadFrame(tkeyclient.Cmd, int) ([]byte, tkeyclient.FramingHdr, error) {
+	d.pending = false
+	return nil, tkeyclient.FramingHdr{}, fmt.Errorf("Read timeout")
+}
+
+func (d *deadDevice) Close() error {
+	d.closed = true
+	return nil
+}
+
+func TestWatchdogWedged(t *testing.T) {
+	var userSecret [UserSecretSize]byte
+
+	x := newX25519(&deadDevice{}, []Option{WithWatchdog(time.Second)})
+
+	if _, err := x.GetPubKey("test", userSecret, false); !errors.Is(err, ErrDeviceWedged) {
+		t.Fatalf("GetPubKey: got %v, want ErrDeviceWedged", err)
+	}
+	if _, err := x.GetPubKey("test", userSecret, false); !errors.Is(err, ErrDeviceWedged) {
+		t.Fatalf("GetPubKey after wedged: got %v, want ErrDeviceWedged", err)
+	}
+}
+
+func TestWatchdogReconnect(t *testing.T) {
+	var userSecret [UserSecretSize]byte
+
+	want, err := newX25519(&fakeDevice{cdi: 1}, nil).GetPubKey("test", userSecret, false)
+	if err != nil {
+		t.Fatalf("GetPubKey: %v", err)
+	}
+
+	dead := &deadDevice{}
+	reconnects := 0
+	x := newX25519(dead, []Option{
+		WithWatchdog(time.Second),
+		WithReconnect(func() (Transport, error) {
+			reconnects++
+			return &fakeDevice{cdi: 1}, nil
+		}),
+	})
+
+	var timeoutErr *TimeoutError
+	if _, err = x.GetPubKey("test", userSecret, false); !errors.As(err, &timeoutErr) {
+		t.Fatalf("GetPubKey: got %v, want *TimeoutError", err)
+	}
+	if reconnects != 1 || !dead.closed {
+		t.Fatalf("reconnected %d times, old connection closed %v", reconnects, dead.closed)
+	}
+
+	got, err := x.GetPubKey("test", userSecret, false)
+	if err != nil {
+		t.Fatalf("GetPubKey after reconnect: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("GetPubKey after reconnect: got %x, want %x", got, want)
+	}
+}
+
+func TestWatchdogReconnectFails(t *testing.T) {
+	var userSecret [UserSecretSize]byte
+
+	for _, tt := range []struct {
+		name    string
+		connect func() (Transport, error)
+	}{
+		{"connect fails", func() (Transport, error) { return nil, errors.New("no TKey") }},
+		{"still dead", func() (Transport, error) { return &deadDevice{}, nil }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			x := newX25519(&deadDevice{}, []Option{WithWatchdog(time.Second), WithReconnect(tt.connect)})
+
+			if _, err := x.GetPubKey("test", userSecret, false); !errors.Is(err, ErrDeviceWedged) {
+				t.Fatalf("GetPubKey: got %v, want ErrDeviceWedged", err)
+			}
+		})
+	}
+}
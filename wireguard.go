@@ -0,0 +1,23 @@
+// Copyright (C) 2026 - Daniel Lublin
+// SPDX-License-Identifier: GPL-2.0-only
+
+package tkeyx25519
+
+import (
+	"encoding/base64"
+)
+
+// WireGuardPublicKey encodes the X25519 public key pub as WireGuard
+// does in its configuration and in the output of "wg pubkey": the 32
+// byte key in standard base64, with padding (44 characters). pub is
+// expected to be 32 bytes.
+//
+// This is only good for the public half: a TKey's key can be put in
+// the [Peer] PublicKey of another host's WireGuard configuration, for
+// example to recognize the TKey's owner. WireGuard itself can not use
+// the TKey as its own identity, since it needs the private key, which
+// never leaves the TKey, and does the handshake ECDH in the kernel or
+// in software.
+func WireGuardPublicKey(pub []byte) string {
+	return base64.StdEncoding.EncodeToString(pub)
+}